@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/notifier"
+)
+
+// controller feeds events to the notifiers and carries out the commands
+// other MPD clients send to the monitor's channel:
+//
+//	renotify   notify the current song again
+//	mute [30m] hold back notifications, until unmute or for a while
+//	unmute     resume notifications
+//	test       send the message itself as a notification
+//	reload     re-read the config file and set up the notifiers again
+//
+// Handle is used from a single goroutine. Muting applies where the
+// notifiers deliver, through a notifier.Gate asking open, so that events
+// the coalescers still hold back are muted as well.
+type controller struct {
+	notifiers notifier.Notifier
+	reload    func() (notifier.Notifier, error)
+	debug     bool
+
+	mu         sync.Mutex // guards muted and mutedUntil, read by open
+	muted      bool
+	mutedUntil time.Time // zero while muted until unmute
+}
+
+// Handle notifies about ev or runs the command it carries.
+func (c *controller) Handle(ev monitor.Event) {
+	if msg, ok := ev.(monitor.MessageReceived); ok {
+		c.command(msg)
+		return
+	}
+
+	c.mu.Lock()
+	expired := c.muted && !c.mutedUntil.IsZero() && !time.Now().Before(c.mutedUntil)
+	if expired {
+		c.muted = false
+	}
+	c.mu.Unlock()
+	if expired {
+		log.Println("🔔 Notifications resumed")
+	}
+
+	c.notify(ev)
+}
+
+// open reports whether notifications may be delivered, i.e. they are not
+// muted. It is safe for concurrent use.
+func (c *controller) open() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.muted || (!c.mutedUntil.IsZero() && !time.Now().Before(c.mutedUntil))
+}
+
+func (c *controller) setMute(muted bool, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.muted, c.mutedUntil = muted, until
+}
+
+func (c *controller) command(msg monitor.MessageReceived) {
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "renotify":
+		snap := msg.Snapshot()
+		if !snap.Playing() {
+			// Song notifications are only sent while playing
+			log.Printf("⚠️  Nothing to renotify: the player is not playing (%s)", snap.State())
+			return
+		}
+		if c.mutedWarning("renotify") {
+			return
+		}
+		c.notify(monitor.SongChanged{Header: msg.Header, New: snap.Song})
+
+	case "mute":
+		if len(fields) == 1 {
+			c.setMute(true, time.Time{})
+			log.Println("🔕 Notifications muted")
+			return
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d <= 0 {
+			log.Printf("⚠️  Invalid mute duration %q", fields[1])
+			return
+		}
+		until := time.Now().Add(d)
+		c.setMute(true, until)
+		log.Printf("🔕 Notifications muted until %s", until.Format("15:04:05"))
+
+	case "unmute":
+		c.setMute(false, time.Time{})
+		log.Println("🔔 Notifications resumed")
+
+	case "test":
+		if c.mutedWarning("test") {
+			return
+		}
+		c.notify(msg)
+
+	case "reload":
+		n, err := c.reload()
+		if err != nil {
+			log.Printf("⚠️  Reload failed: %v", err)
+			return
+		}
+		c.notifiers.Close()
+		c.notifiers = n
+		log.Println("🔄 Notifications reloaded; MPD settings take effect after a restart")
+
+	default:
+		log.Printf("⚠️  Unknown command %q on channel %s", fields[0], msg.Channel)
+	}
+}
+
+// mutedWarning logs that cmd has no effect and reports true while
+// notifications are muted.
+func (c *controller) mutedWarning(cmd string) bool {
+	if c.open() {
+		return false
+	}
+	log.Printf("⚠️  Notifications are muted; unmute to %s", cmd)
+	return true
+}
+
+func (c *controller) notify(ev monitor.Event) {
+	if err := c.notifiers.Notify(ev); err != nil && c.debug {
+		log.Printf("⚠️  Failed to send notification: %v", err)
+	}
+}
+
+// Close closes the notifiers.
+func (c *controller) Close() error {
+	return c.notifiers.Close()
+}
@@ -0,0 +1,404 @@
+// Package gntptest provides a fake GNTP 1.0 receiver for tests.
+//
+// The server accepts REGISTER and NOTIFY requests, keeps their parsed
+// headers and binary resources, and can be told to require a password,
+// answer slowly or reject requests.
+package gntptest
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GNTP error codes sent in -ERROR responses.
+const (
+	ErrInvalidRequest      = 300
+	ErrRequiredHeader      = 303
+	ErrNotAuthorized       = 400
+	ErrUnknownApplication  = 401
+	ErrUnknownNotification = 402
+	ErrInternal            = 500
+)
+
+const resourcePrefix = "x-growl-resource://"
+
+// Message is one request received by the server.
+type Message struct {
+	Action  string            // REGISTER or NOTIFY
+	Headers map[string]string // the request's main header block
+	// Types holds one header block per notification type of a REGISTER.
+	Types []map[string]string
+	// Resources maps identifiers to the binary data sent with the request.
+	Resources map[string][]byte
+}
+
+// Header returns the value of header name from the main block.
+func (m Message) Header(name string) string {
+	return m.Headers[name]
+}
+
+// Resource returns the data referenced by a header value of the form
+// x-growl-resource://ID, or nil if value is not such a reference.
+func (m Message) Resource(value string) []byte {
+	if !strings.HasPrefix(value, resourcePrefix) {
+		return nil
+	}
+	return m.Resources[strings.TrimPrefix(value, resourcePrefix)]
+}
+
+// Icon returns the binary icon of a NOTIFY, if it was sent as a resource.
+func (m Message) Icon() []byte {
+	return m.Resource(m.Header("Notification-Icon"))
+}
+
+// TypeNames returns the notification names announced by a REGISTER.
+func (m Message) TypeNames() []string {
+	names := make([]string, 0, len(m.Types))
+	for _, t := range m.Types {
+		names = append(names, t["Notification-Name"])
+	}
+	return names
+}
+
+// Server is a fake GNTP receiver listening on a loopback TCP port.
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	password string
+	delay    time.Duration
+	failCode int
+	failDesc string
+	messages []Message
+	received chan struct{}
+}
+
+// NewServer starts a server on a random loopback port.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{ln: ln, received: make(chan struct{}, 1)}
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host returns the address the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.ln.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// SetPassword makes the server reject requests without a matching key
+// hash. An empty password accepts everything.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// SetDelay makes the server wait d before answering each request.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Fail makes the server answer every request with an -ERROR response. A
+// zero code returns to normal operation. Failed requests are still recorded.
+func (s *Server) Fail(code int, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failCode = code
+	s.failDesc = description
+}
+
+// Messages returns every request received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Notifications returns the NOTIFY requests received so far.
+func (s *Server) Notifications() []Message {
+	return s.filter("NOTIFY")
+}
+
+// Registrations returns the REGISTER requests received so far.
+func (s *Server) Registrations() []Message {
+	return s.filter("REGISTER")
+}
+
+// WaitNotifications waits until at least n NOTIFY requests arrived or
+// timeout passes, and returns the notifications received.
+func (s *Server) WaitNotifications(n int, timeout time.Duration) []Message {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		if got := s.Notifications(); len(got) >= n {
+			return got
+		}
+		select {
+		case <-s.received:
+		case <-deadline.C:
+			return s.Notifications()
+		}
+	}
+}
+
+// Reset forgets the requests received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) filter(action string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Message
+	for _, m := range s.messages {
+		if m.Action == action {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	r := bufio.NewReader(conn)
+	msg, auth, err := readMessage(r)
+	if err != nil {
+		respondError(conn, "", ErrInvalidRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	password, delay := s.password, s.delay
+	failCode, failDesc := s.failCode, s.failDesc
+	s.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if password != "" && !checkKey(auth, password) {
+		respondError(conn, msg.Action, ErrNotAuthorized, "password mismatch")
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+	select {
+	case s.received <- struct{}{}:
+	default:
+	}
+
+	if failCode != 0 {
+		respondError(conn, msg.Action, failCode, failDesc)
+		return
+	}
+
+	fmt.Fprintf(conn, "GNTP/1.0 -OK NONE\r\nResponse-Action: %s\r\n\r\n", msg.Action)
+}
+
+func respondError(w io.Writer, action string, code int, description string) {
+	fmt.Fprintf(w, "GNTP/1.0 -ERROR NONE\r\n")
+	if action != "" {
+		fmt.Fprintf(w, "Response-Action: %s\r\n", action)
+	}
+	fmt.Fprintf(w, "Error-Code: %d\r\nError-Description: %s\r\n\r\n", code, description)
+}
+
+// readMessage parses a request and returns it with the key hash part of
+// the information line ("" if none was sent).
+func readMessage(r *bufio.Reader) (Message, string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return Message{}, "", err
+	}
+
+	// GNTP/1.0 <action> <encryption> [<hash-alg>:<key-hash>.<salt>]
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "GNTP/1.0" {
+		return Message{}, "", fmt.Errorf("bad information line %q", line)
+	}
+	if fields[2] != "NONE" {
+		return Message{}, "", fmt.Errorf("encryption %q not supported", fields[2])
+	}
+	auth := ""
+	if len(fields) > 3 {
+		auth = fields[3]
+	}
+
+	msg := Message{Action: fields[1], Resources: make(map[string][]byte)}
+	if msg.Headers, err = readBlock(r); err != nil {
+		return msg, auth, err
+	}
+
+	refs := make(map[string]bool)
+	addResourceRefs(refs, msg.Headers)
+
+	if msg.Action == "REGISTER" {
+		count, err := strconv.Atoi(msg.Headers["Notifications-Count"])
+		if err != nil {
+			return msg, auth, fmt.Errorf("bad Notifications-Count")
+		}
+		for i := 0; i < count; i++ {
+			block, err := readBlock(r)
+			if err != nil {
+				return msg, auth, err
+			}
+			msg.Types = append(msg.Types, block)
+			addResourceRefs(refs, block)
+		}
+	}
+
+	for range refs {
+		block, err := readBlock(r)
+		if err != nil {
+			return msg, auth, err
+		}
+		size, err := strconv.Atoi(block["Length"])
+		if err != nil {
+			return msg, auth, fmt.Errorf("bad resource Length")
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return msg, auth, err
+		}
+		msg.Resources[block["Identifier"]] = data
+	}
+
+	return msg, auth, nil
+}
+
+// readBlock reads "Name: value" lines up to a blank line, skipping blank
+// lines before the block.
+func readBlock(r *bufio.Reader) (map[string]string, error) {
+	block := make(map[string]string)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			if len(block) == 0 {
+				continue
+			}
+			return block, nil
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad header line %q", line)
+		}
+		block[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// addResourceRefs adds the resource identifiers referenced in block to refs.
+// Each resource is sent once however often it is referenced.
+func addResourceRefs(refs map[string]bool, block map[string]string) {
+	for _, v := range block {
+		if strings.HasPrefix(v, resourcePrefix) {
+			refs[strings.TrimPrefix(v, resourcePrefix)] = true
+		}
+	}
+}
+
+// checkKey verifies a "<alg>:<key-hash>.<salt>" string against password.
+func checkKey(auth, password string) bool {
+	alg, rest, ok := strings.Cut(auth, ":")
+	if !ok {
+		return false
+	}
+	keyHash, saltHex, ok := strings.Cut(rest, ".")
+	if !ok {
+		return false
+	}
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToUpper(alg) {
+	case "MD5":
+		newHash = md5.New
+	case "SHA1":
+		newHash = sha1.New
+	case "SHA256":
+		newHash = sha256.New
+	case "SHA512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	h := newHash()
+	h.Write([]byte(password))
+	h.Write(salt)
+	key := h.Sum(nil)
+
+	h = newHash()
+	h.Write(key)
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), keyHash)
+}
@@ -1,4 +1,4 @@
-module go-mpd-notify
+module github.com/cumulus13/go-mpdmon
 
 go 1.25.3
 
@@ -6,10 +6,10 @@ require (
 	github.com/BurntSushi/toml v1.3.2
 	github.com/cumulus13/go-gntp v1.0.3
 	github.com/fhs/gompd/v2 v2.3.0
+	golang.org/x/term v0.38.0
 )
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
-	golang.org/x/term v0.38.0 // indirect
 )
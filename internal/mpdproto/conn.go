@@ -0,0 +1,351 @@
+// Package mpdproto is a minimal MPD protocol client built for the monitor's
+// single-connection loop: every command runs under a deadline, and the
+// connection can sit in idle while another goroutine interrupts it with
+// noidle.
+package mpdproto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// ErrTimeout is returned when MPD does not answer within the deadline.
+var ErrTimeout = errors.New("MPD did not answer in time")
+
+// Pair is one "key: value" line of a response.
+type Pair struct {
+	Key, Value string
+}
+
+// Response holds the lines of a response in the order MPD sent them.
+type Response struct {
+	Pairs  []Pair
+	Binary []byte // payload of a binary response such as readpicture
+}
+
+// Attrs returns the response as a map. Repeated keys keep the last value.
+func (r Response) Attrs() mpd.Attrs {
+	attrs := make(mpd.Attrs, len(r.Pairs))
+	for _, p := range r.Pairs {
+		attrs[p.Key] = p.Value
+	}
+	return attrs
+}
+
+// List splits the response into one map per entry, each entry starting at
+// a line with key startKey.
+func (r Response) List(startKey string) []mpd.Attrs {
+	var list []mpd.Attrs
+	for _, p := range r.Pairs {
+		if p.Key == startKey || len(list) == 0 {
+			list = append(list, mpd.Attrs{})
+		}
+		list[len(list)-1][p.Key] = p.Value
+	}
+	return list
+}
+
+// Conn is a connection to MPD. It is not safe for concurrent use, except
+// that NoIdle may be called while another goroutine waits in ReadIdle.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	version string
+}
+
+// Dial connects to MPD, reads the greeting and sends password if it is
+// not empty. timeout applies to the dial and to every later command; zero
+// means no limit.
+func Dial(network, addr, password string, timeout time.Duration) (*Conn, error) {
+	nc, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, timeoutError(err)
+	}
+
+	c := &Conn{conn: nc, r: bufio.NewReader(nc), timeout: timeout}
+
+	c.setDeadline()
+	line, err := c.readLine()
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK MPD ") {
+		nc.Close()
+		return nil, textproto.ProtocolError(fmt.Sprintf("unexpected greeting %q", line))
+	}
+	c.version = strings.TrimPrefix(line, "OK MPD ")
+
+	if password != "" {
+		if _, err := c.Command("password", password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Version returns the protocol version announced by the server.
+func (c *Conn) Version() string {
+	return c.version
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Cmd is one command of a command list.
+type Cmd struct {
+	Name string
+	Args []string
+}
+
+// NewCmd returns a Cmd for name and args.
+func NewCmd(name string, args ...string) Cmd {
+	return Cmd{Name: name, Args: args}
+}
+
+// Command sends a command and returns its response. An ACK is returned as
+// an mpd.Error.
+func (c *Conn) Command(name string, args ...string) (Response, error) {
+	if err := c.send(name, args...); err != nil {
+		return Response{}, err
+	}
+	resp, _, err := c.readResponse()
+	return resp, err
+}
+
+// CommandList sends cmds in one command_list_ok_begin batch, costing a
+// single round trip, and returns one response per command. MPD stops at
+// the first failing command: the responses before it are returned along
+// with its ACK, whose CommandListIndex tells which command failed.
+func (c *Conn) CommandList(cmds ...Cmd) ([]Response, error) {
+	var sb strings.Builder
+	sb.WriteString("command_list_ok_begin\n")
+	for _, cmd := range cmds {
+		writeCommand(&sb, cmd.Name, cmd.Args)
+	}
+	sb.WriteString("command_list_end\n")
+
+	c.setDeadline()
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return nil, timeoutError(err)
+	}
+
+	resps := make([]Response, 0, len(cmds))
+	for range cmds {
+		resp, end, err := c.readResponse()
+		if err != nil {
+			return resps, err
+		}
+		if end != "list_OK" {
+			return resps, textproto.ProtocolError(fmt.Sprintf("command list ended early with %q", end))
+		}
+		resps = append(resps, resp)
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return resps, err
+	}
+	if line != "OK" {
+		return resps, textproto.ProtocolError(fmt.Sprintf("unexpected end of command list %q", line))
+	}
+	return resps, nil
+}
+
+// Binary fetches the whole of a chunked binary response such as
+// readpicture or albumart. It returns nil if MPD has no data for uri.
+func (c *Conn) Binary(name, uri string) ([]byte, error) {
+	first, err := c.Command(name, uri, "0")
+	if err != nil {
+		return nil, err
+	}
+	return c.BinaryRest(name, uri, first)
+}
+
+// BinaryRest fetches the chunks following first, the response to name
+// with offset 0, e.g. when that was part of a command list.
+func (c *Conn) BinaryRest(name, uri string, first Response) ([]byte, error) {
+	data := first.Binary
+	size, _ := strconv.Atoi(first.Attrs()["size"])
+	for len(data) < size {
+		resp, err := c.Command(name, uri, strconv.Itoa(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Binary) == 0 {
+			return nil, textproto.ProtocolError(fmt.Sprintf("%s returned an empty chunk at offset %d", name, len(data)))
+		}
+		data = append(data, resp.Binary...)
+	}
+	return data, nil
+}
+
+// BeginIdle sends idle for subsystems (all if none) without waiting for
+// the answer; call ReadIdle next.
+func (c *Conn) BeginIdle(subsystems ...string) error {
+	return c.send("idle", subsystems...)
+}
+
+// ReadIdle waits for the answer to idle and returns the changed
+// subsystems. It has no deadline of its own: it returns when MPD reports a
+// change or after NoIdle.
+func (c *Conn) ReadIdle() ([]string, error) {
+	resp, _, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, p := range resp.Pairs {
+		if p.Key == "changed" {
+			changed = append(changed, p.Value)
+		}
+	}
+	return changed, nil
+}
+
+// NoIdle asks MPD to end the current idle. The pending ReadIdle then
+// returns, and must do so within the timeout.
+func (c *Conn) NoIdle() error {
+	if _, err := io.WriteString(c.conn, "noidle\n"); err != nil {
+		return timeoutError(err)
+	}
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return nil
+}
+
+func (c *Conn) send(name string, args ...string) error {
+	var sb strings.Builder
+	writeCommand(&sb, name, args)
+
+	if name == "idle" {
+		// idle may legitimately wait forever
+		c.conn.SetDeadline(time.Time{})
+	} else {
+		c.setDeadline()
+	}
+
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return timeoutError(err)
+	}
+	return nil
+}
+
+// readResponse reads one response up to OK or, within a command list,
+// list_OK, and returns which of the two ended it.
+func (c *Conn) readResponse() (Response, string, error) {
+	var resp Response
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return Response{}, "", err
+		}
+		if line == "OK" || line == "list_OK" {
+			return resp, line, nil
+		}
+		if strings.HasPrefix(line, "ACK ") {
+			return Response{}, "", parseAck(line)
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return Response{}, "", textproto.ProtocolError(fmt.Sprintf("unexpected response line %q", line))
+		}
+		if key == "binary" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return Response{}, "", textproto.ProtocolError(fmt.Sprintf("bad binary length %q", value))
+			}
+			data := make([]byte, n+1) // data plus trailing newline
+			if _, err := io.ReadFull(c.r, data); err != nil {
+				return Response{}, "", timeoutError(err)
+			}
+			resp.Binary = data[:n]
+		}
+		resp.Pairs = append(resp.Pairs, Pair{Key: key, Value: value})
+	}
+}
+
+func (c *Conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", timeoutError(err)
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (c *Conn) setDeadline() {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// timeoutError marks deadline errors with ErrTimeout, keeping the
+// original error for errors.Is and errors.As.
+func timeoutError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// parseAck parses "ACK [code@index] {command} message".
+func parseAck(line string) error {
+	ack := mpd.Error{Message: line}
+
+	rest := strings.TrimPrefix(line, "ACK ")
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "]"); end > 0 {
+			code, index, _ := strings.Cut(rest[1:end], "@")
+			n, _ := strconv.Atoi(code)
+			ack.Code = mpd.ErrorCode(n)
+			ack.CommandListIndex, _ = strconv.Atoi(index)
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	if strings.HasPrefix(rest, "{") {
+		if end := strings.Index(rest, "}"); end > 0 {
+			ack.CommandName = rest[1:end]
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	ack.Message = rest
+
+	return ack
+}
+
+func writeCommand(sb *strings.Builder, name string, args []string) {
+	sb.WriteString(name)
+	for _, arg := range args {
+		sb.WriteByte(' ')
+		sb.WriteString(quote(arg))
+	}
+	sb.WriteByte('\n')
+}
+
+// quote returns arg as an MPD string argument.
+func quote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(arg) + `"`
+}
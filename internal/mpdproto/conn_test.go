@@ -0,0 +1,79 @@
+package mpdproto_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+	"github.com/cumulus13/go-mpdmon/mpdtest"
+)
+
+const timeout = 200 * time.Millisecond
+
+func dial(t *testing.T) (*mpdtest.Server, *mpdproto.Conn) {
+	t.Helper()
+	srv, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	conn, err := mpdproto.Dial("tcp", srv.Addr(), "", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+// timedOut fails t unless err is ErrTimeout and took about the timeout.
+func timedOut(t *testing.T, err error, took time.Duration) {
+	t.Helper()
+	if !errors.Is(err, mpdproto.ErrTimeout) {
+		t.Fatalf("error = %v, want ErrTimeout", err)
+	}
+	if took < timeout || took > 5*timeout {
+		t.Errorf("gave up after %v, want about %v", took, timeout)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	srv, conn := dial(t)
+	srv.Stall("status", time.Minute)
+
+	begin := time.Now()
+	_, err := conn.Command("status")
+	timedOut(t, err, time.Since(begin))
+}
+
+func TestCommandListTimeout(t *testing.T) {
+	srv, conn := dial(t)
+	srv.Stall("currentsong", time.Minute)
+
+	begin := time.Now()
+	_, err := conn.CommandList(mpdproto.NewCmd("status"), mpdproto.NewCmd("currentsong"))
+	timedOut(t, err, time.Since(begin))
+}
+
+func TestBinaryTimeout(t *testing.T) {
+	srv, conn := dial(t)
+	srv.SetPicture("a.flac", make([]byte, 100), "image/png")
+	srv.Stall("readpicture", time.Minute)
+
+	begin := time.Now()
+	_, err := conn.Binary("readpicture", "a.flac")
+	timedOut(t, err, time.Since(begin))
+}
+
+func TestDeadlineRenewedPerCommand(t *testing.T) {
+	srv, conn := dial(t)
+	srv.Stall("status", timeout/2)
+
+	// Each command gets the full timeout, however long the connection lives
+	for range 4 {
+		if _, err := conn.Command("status"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,65 +1,123 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/cumulus13/go-gntp"
-	"github.com/fhs/gompd/v2/mpd"
-	"golang.org/x/term"
-)
 
-const (
-	// ANSI color codes for terminal
-	colorReset  = "\033[0m"
-	colorCyan   = "\033[96m"  // track/title
-	colorYellow = "\033[93m"  // artist
-	colorOrange = "\033[38;5;216m" // album
-	colorBlue   = "\033[94m"  // bitrate
-	colorGreen  = "\033[92m"  // filepath
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/notifier"
+	"github.com/cumulus13/go-mpdmon/render"
 )
 
 type Config struct {
 	MPD struct {
-		Host    string `toml:"host"`
-		Port    string `toml:"port"`
-		Timeout int    `toml:"timeout"`
-	} `toml:"mpd"`
-
-	GNTP struct {
 		Host     string `toml:"host"`
-		Port     int    `toml:"port"`
+		Port     string `toml:"port"`
 		Password string `toml:"password"`
-		IconMode string `toml:"icon_mode"` // binary, dataurl, fileurl, httpurl
-	} `toml:"gntp"`
+		Timeout  int    `toml:"timeout"`
+
+		// ClearErrors runs clearerror after reporting an MPD error.
+		ClearErrors bool `toml:"clear_errors"`
+
+		// Channel is the client-to-client channel on which the monitor
+		// takes commands; empty takes none.
+		Channel string `toml:"channel"`
+
+		// Partitions lists the MPD partitions to watch, each on its own
+		// connection. Empty watches the default partition only.
+		Partitions []string `toml:"partitions"`
+
+		// Servers replaces host, port and password when several MPD
+		// servers are monitored.
+		Servers []ServerConfig `toml:"servers"`
+	} `toml:"mpd"`
+
+	Reconnect struct {
+		InitialDelay float64 `toml:"initial_delay"` // seconds
+		MaxDelay     float64 `toml:"max_delay"`     // seconds
+		Multiplier   float64 `toml:"multiplier"`
+		Jitter       float64 `toml:"jitter"`       // fraction of the delay, 0..1
+		MaxAttempts  int     `toml:"max_attempts"` // 0 = retry forever
+	} `toml:"reconnect"`
+
+	Notify struct {
+		VolumeWindow float64 `toml:"volume_window"` // seconds; 0 notifies every step
+		Queue        bool    `toml:"queue"`         // tracks added, removed, moved, cleared
+		Database     bool    `toml:"database"`      // update started and finished
+		Library      bool    `toml:"library"`       // new albums and artists after an update
+		UpNext       float64 `toml:"up_next"`       // seconds before a song ends; 0 = off
+		SkipWindow   float64 `toml:"skip_window"`   // seconds a song must play to be notified; 0 = off
+	} `toml:"notify"`
+
+	// GNTP is the single Growl target used when no [[notifiers]] are listed.
+	GNTP notifier.Config `toml:"gntp"`
+
+	Notifiers []notifier.Config `toml:"notifiers"`
 }
 
-type AppState struct {
-	lastSongFile string
-	lastState    string
-	conn         *mpd.Client
-	gntp         *gntp.Client
-	config       Config
-	debug        bool
-	gntpEnabled  bool
+// ServerConfig is one [[mpd.servers]] entry.
+type ServerConfig struct {
+	Name     string `toml:"name"`
+	Address  string `toml:"address"` // [password@]host[:port], socket path or @abstract
+	Password string `toml:"password"`
+
+	Partitions []string `toml:"partitions"` // default: [mpd] partitions
+}
+
+// mpdHostOverrides applies the MPD host and password settings on top of
+// the config file, each overriding the ones before: a password@host in
+// the config, then MPD_HOST (env), then the -mpd-host (flagHost) and
+// -mpd-password (flagPass) flags. A host without a password keeps the
+// password set before it.
+func mpdHostOverrides(cfg *Config, env, flagHost, flagPass string) {
+	// A host of the form password@host carries the password too
+	if host, password := monitor.ParseHost(cfg.MPD.Host); password != "" {
+		cfg.MPD.Host, cfg.MPD.Password = host, password
+	}
+
+	for _, value := range []string{env, flagHost} {
+		if host, password := monitor.ParseHost(value); host != "" {
+			cfg.MPD.Host = host
+			if password != "" {
+				cfg.MPD.Password = password
+			}
+		}
+	}
+	if flagPass != "" {
+		cfg.MPD.Password = flagPass
+	}
 }
 
 func loadConfig(configPath string) (Config, error) {
 	var cfg Config
 
 	// Default values
-	cfg.MPD.Host = "localhost"
+	cfg.MPD.Host = "" // discover the local socket, else localhost
 	cfg.MPD.Port = "6600"
 	cfg.MPD.Timeout = 10
+	cfg.Reconnect.InitialDelay = 1
+	cfg.Reconnect.MaxDelay = 60
+	cfg.Reconnect.Multiplier = 2
+	cfg.Reconnect.Jitter = 0.2
+	cfg.Reconnect.MaxAttempts = 0
+	cfg.Notify.VolumeWindow = 0.5
+	cfg.Notify.Database = true
+	cfg.Notify.Library = true
+	cfg.Notify.SkipWindow = 1
+	cfg.GNTP.Type = "gntp"
 	cfg.GNTP.Host = "localhost"
 	cfg.GNTP.Port = 23053
-	cfg.GNTP.Password = ""
 	cfg.GNTP.IconMode = "binary" // binary mode recommended for Windows
 
 	if configPath != "" {
@@ -73,884 +131,167 @@ func loadConfig(configPath string) (Config, error) {
 	return cfg, nil
 }
 
-func getEnvOrDefault(key, def string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
-	}
-	return def
-}
+// monitorConfigs returns one monitor.Config per MPD server and partition:
+// the [[mpd.servers]] entries if there are any, else the single [mpd]
+// server.
+func monitorConfigs(cfg Config, debug bool) []monitor.Config {
+	base := monitor.Config{
+		Host:     cfg.MPD.Host,
+		Port:     cfg.MPD.Port,
+		Password: cfg.MPD.Password,
+		Timeout:  cfg.MPD.Timeout,
+		Channel:  cfg.MPD.Channel,
+		Debug:    debug,
+
+		ClearErrors: cfg.MPD.ClearErrors,
+		UpNext:      seconds(cfg.Notify.UpNext),
+		Reconnect: monitor.ReconnectPolicy{
+			InitialDelay: seconds(cfg.Reconnect.InitialDelay),
+			MaxDelay:     seconds(cfg.Reconnect.MaxDelay),
+			Multiplier:   cfg.Reconnect.Multiplier,
+			Jitter:       cfg.Reconnect.Jitter,
+			MaxAttempts:  cfg.Reconnect.MaxAttempts,
+		},
+	}
+	if len(cfg.MPD.Servers) == 0 {
+		return withPartitions(nil, base, cfg.MPD.Partitions)
+	}
+
+	var configs []monitor.Config
+	for _, server := range cfg.MPD.Servers {
+		mc := base
+		host, port, password := monitor.ParseAddress(server.Address)
+		mc.Host, mc.Port, mc.Password = host, port, password
+
+		// Name servers after their address, leaving out the password
+		// that would show up in log lines and notification titles
+		mc.Name = server.Name
+		if mc.Name == "" {
+			mc.Name = host
+			if port != "" {
+				mc.Name = net.JoinHostPort(host, port)
+			}
+		}
+		if server.Password != "" {
+			mc.Password = server.Password
+		}
 
-func getTerminalWidth() int {
-	// Try to get terminal size
-	fd := int(os.Stdout.Fd())
-	width, _, err := term.GetSize(fd)
-	if err != nil || width <= 0 {
-		return 80 // Default fallback
+		partitions := server.Partitions
+		if len(partitions) == 0 {
+			partitions = cfg.MPD.Partitions
+		}
+		configs = withPartitions(configs, mc, partitions)
 	}
-	return width
+	return configs
 }
 
-func printSeparator() {
-	width := getTerminalWidth()
-	fmt.Println(strings.Repeat("─", width))
-}
-
-func connectMPD(host, port string, timeout int) (*mpd.Client, error) {
-	addr := fmt.Sprintf("%s:%s", host, port)
-
-	client, err := mpd.DialAuthenticated("tcp", addr, "")
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MPD at %s: %v", addr, err)
+// withPartitions appends mc once per partition, or once as is if there
+// are none. Only the first partition subscribes to the command channel
+// and reports database updates, which are shared by the whole server.
+func withPartitions(configs []monitor.Config, mc monitor.Config, partitions []string) []monitor.Config {
+	if len(partitions) == 0 {
+		return append(configs, mc)
+	}
+	for i, partition := range partitions {
+		mc.Partition = partition
+		if i > 0 {
+			mc.Channel = ""
+			mc.NoDatabase = true
+		}
+		configs = append(configs, mc)
 	}
-
-	return client, nil
+	return configs
 }
 
-func setupGNTP(cfg Config, debug bool) (*gntp.Client, bool) {
-	client := gntp.NewClient("MPD Monitor").
-		WithHost(cfg.GNTP.Host).
-		WithPort(cfg.GNTP.Port).
-		WithTimeout(10 * time.Second)
-
-	// Set icon mode based on config
-	switch strings.ToLower(cfg.GNTP.IconMode) {
-	case "dataurl":
-		client.WithIconMode(gntp.IconModeDataURL)
-	case "fileurl":
-		client.WithIconMode(gntp.IconModeFileURL)
-	case "httpurl":
-		client.WithIconMode(gntp.IconModeHttpURL)
-	default:
-		// Binary mode is default and recommended for Windows
-		client.WithIconMode(gntp.IconModeBinary)
+// setupNotifiers creates and registers the configured notification backends.
+// Backends that fail to register are logged and left out.
+func setupNotifiers(cfg Config, artwork notifier.ArtworkSource, debug bool) notifier.Multi {
+	configs := cfg.Notifiers
+	if len(configs) == 0 {
+		configs = []notifier.Config{cfg.GNTP}
 	}
 
-	// Define notification types
-	songChange := gntp.NewNotificationType("song_change").
-		WithDisplayName("Song Changed")
-
-	playerState := gntp.NewNotificationType("player_state").
-		WithDisplayName("Player State")
-
-	// Register notifications
-	if err := client.Register([]*gntp.NotificationType{songChange, playerState}); err != nil {
-		if debug {
-			log.Printf("⚠️  Failed to register with GNTP: %v", err)
+	var active notifier.Multi
+	for _, nc := range configs {
+		if nc.Type == "" {
+			nc.Type = "gntp"
 		}
-		log.Println("⚠️  GNTP/Growl not available - notifications disabled")
-		return nil, false
-	}
-
-	return client, true
-}
-
-func getAlbumArt(conn *mpd.Client, uri string) *gntp.Resource {
-	// Try ReadPicture first (embedded artwork)
-	artwork, err := conn.ReadPicture(uri)
-	if err == nil && len(artwork) > 0 {
-		// Detect content type
-		contentType := "image/jpeg"
-		if len(artwork) > 8 {
-			if artwork[0] == 0x89 && artwork[1] == 0x50 && artwork[2] == 0x4E && artwork[3] == 0x47 {
-				contentType = "image/png"
-			}
+		if nc.Port == 0 {
+			nc.Port = cfg.GNTP.Port
 		}
-		return gntp.LoadResourceFromBytes(artwork, contentType)
-	}
-
-	// Try AlbumArt (external artwork)
-	artwork, err = conn.AlbumArt(uri)
-	if err == nil && len(artwork) > 0 {
-		contentType := "image/jpeg"
-		if len(artwork) > 8 {
-			if artwork[0] == 0x89 && artwork[1] == 0x50 && artwork[2] == 0x4E && artwork[3] == 0x47 {
-				contentType = "image/png"
-			}
+		if nc.IconMode == "" {
+			nc.IconMode = cfg.GNTP.IconMode
 		}
-		return gntp.LoadResourceFromBytes(artwork, contentType)
-	}
 
-	return nil
-}
+		n, err := notifier.New(nc, artwork)
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			continue
+		}
 
-func formatBitrate(attrs mpd.Attrs) string {
-	if bitrate, ok := attrs["audio"]; ok {
-		// audio format: "samplerate:bits:channels"
-		parts := strings.Split(bitrate, ":")
-		if len(parts) >= 1 {
-			sampleRate := parts[0]
-			if sr, err := strconv.Atoi(sampleRate); err == nil {
-				kbps := sr / 1000
-				return fmt.Sprintf("%d kHz", kbps)
+		if err := n.Register(); err != nil {
+			if debug {
+				log.Printf("⚠️  Failed to register with %s: %v", nc, err)
 			}
+			log.Printf("⚠️  %s not available - notifications disabled", nc)
+			continue
 		}
-	}
 
-	// Fallback to bitrate field if available
-	if bitrate, ok := attrs["bitrate"]; ok {
-		return fmt.Sprintf("%s kbps", bitrate)
+		log.Printf("📢 %s registered (icon mode: %s)", nc, nc.IconMode)
+		active = append(active, n)
 	}
 
-	return "N/A"
+	return active
 }
 
-func formatDuration(seconds string) string {
-	if seconds == "" {
-		return "0:00"
+// buildNotifiers sets up the backends and wraps them in the filtering and
+// coalescing the [notify] settings ask for. Nothing is delivered while open
+// reports false.
+func buildNotifiers(cfg Config, artwork notifier.ArtworkSource, open func() bool, debug bool) notifier.Notifier {
+	// Optional - don't fail if not available
+	backends := setupNotifiers(cfg, artwork, debug)
+	if len(backends) == 0 {
+		log.Println("📢 Notifications: disabled")
 	}
+	gate := notifier.NewGate(backends, open)
 
-	sec, err := strconv.ParseFloat(seconds, 64)
-	if err != nil {
-		return "0:00"
+	// Leave out the notifications that are switched off
+	var off []monitor.Kind
+	if !cfg.Notify.Queue {
+		off = append(off, monitor.KindTracksAdded, monitor.KindTracksRemoved, monitor.KindTracksMoved, monitor.KindQueueCleared)
 	}
-
-	mins := int(sec) / 60
-	secs := int(sec) % 60
-
-	return fmt.Sprintf("%d:%02d", mins, secs)
-}
-
-func formatCurrentPlaying(song mpd.Attrs, status mpd.Attrs) string {
-	pos := status["song"]
-	total := status["playlistlength"]
-	elapsed := formatDuration(status["elapsed"])
-	duration := formatDuration(song["duration"])
-	track := song["Track"]
-	title := song["Title"]
-	artist := song["Artist"]
-	album := song["Album"]
-	bitrate := formatBitrate(status)
-	filepath := song["file"]
-
-	if title == "" {
-		title = filepath
+	if !cfg.Notify.Database {
+		off = append(off, monitor.KindUpdateStarted, monitor.KindUpdateFinished)
 	}
-
-	if track == "" {
-		track = "?"
+	if !cfg.Notify.Library {
+		off = append(off, monitor.KindLibraryDigest)
 	}
+	filtered := notifier.NewFilter(gate, off...)
 
-	var sb strings.Builder
+	// Volume sliders produce a burst of changes; notify the final level only
+	volume := notifier.NewCoalescer(filtered, seconds(cfg.Notify.VolumeWindow), monitor.KindVolumeChanged)
 
-	// Position/Total/Track. Title with time
-	sb.WriteString(fmt.Sprintf("%s/%s/%s. %s\n", pos, total, track, title))
-	sb.WriteString(fmt.Sprintf("%s / %s\n", elapsed, duration))
+	// Likewise skipping through tracks; notify the one that keeps playing
+	songs := notifier.NewCoalescer(volume, seconds(cfg.Notify.SkipWindow), monitor.KindSongChanged)
 
-	// Artist
-	if artist != "" {
-		sb.WriteString(fmt.Sprintf("🎤 %s\n", artist))
-	}
-
-	// Album
-	if album != "" {
-		sb.WriteString(fmt.Sprintf("💿 %s\n", album))
+	if debug {
+		onError := func(err error) {
+			log.Printf("⚠️  Failed to send notification: %v", err)
+		}
+		volume.OnError, songs.OnError = onError, onError
 	}
-
-	// Bitrate
-	sb.WriteString(fmt.Sprintf("🎵 %s\n", bitrate))
-
-	// Filepath
-	sb.WriteString(fmt.Sprintf("📁 %s", filepath))
-
-	return sb.String()
+	return songs
 }
 
-func formatConsolePlaying(song mpd.Attrs, status mpd.Attrs) string {
-	pos := status["song"]
-	total := status["playlistlength"]
-	elapsed := formatDuration(status["elapsed"])
-	duration := formatDuration(song["duration"])
-	track := song["Track"]
-	title := song["Title"]
-	artist := song["Artist"]
-	album := song["Album"]
-	bitrate := formatBitrate(status)
-	filepath := song["file"]
-
-	if title == "" {
-		title = filepath
-	}
-
-	if track == "" {
-		track = "?"
-	}
-
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("%s▶ %s/%s/%s. %s%s\n", colorCyan, pos, total, track, title, colorReset))
-	sb.WriteString(fmt.Sprintf("%s  🕓 %s / %s%s\n", colorCyan, elapsed, duration, colorReset))
-
-	if artist != "" {
-		sb.WriteString(fmt.Sprintf("%s  🎤 %s%s\n", colorYellow, artist, colorReset))
-	}
-
-	if album != "" {
-		sb.WriteString(fmt.Sprintf("%s  💿 %s%s\n", colorOrange, album, colorReset))
-	}
-
-	sb.WriteString(fmt.Sprintf("%s  🎵 %s%s\n", colorBlue, bitrate, colorReset))
-	sb.WriteString(fmt.Sprintf("%s  📁 %s%s", colorGreen, filepath, colorReset))
-
-	return sb.String()
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
 }
 
-func sendNotification(state *AppState, event, title, message string, icon *gntp.Resource) error {
-	// Skip if GNTP not enabled
-	if !state.gntpEnabled || state.gntp == nil {
-		return nil
-	}
-
-	opts := gntp.NewNotifyOptions()
-
-	if icon != nil {
-		opts.WithIcon(icon)
-	}
-
-	err := state.gntp.NotifyWithOptions(event, title, message, opts)
-	if err != nil && state.debug {
-		return err
+func getEnvOrDefault(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
 	}
-	return nil
-}
-
-// func reconnectMPD(state *AppState) error {
-// 	if state.conn != nil {
-// 		state.conn.Close()
-// 	}
-
-// 	conn, err := connectMPD(state.config.MPD.Host, state.config.MPD.Port, state.config.MPD.Timeout)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	state.conn = conn
-// 	return nil
-// }
-
-func reconnectMPD(state *AppState) error {
-    if state.conn != nil {
-        state.conn.Close()
-        state.conn = nil
-    }
-    
-    maxRetries := 5
-    for i := 0; i < maxRetries; i++ {
-        conn, err := connectMPD(state.config.MPD.Host, state.config.MPD.Port, state.config.MPD.Timeout)
-        if err != nil {
-            if state.debug {
-                log.Printf("🔄 Reconnect attempt %d/%d failed: %v", i+1, maxRetries, err)
-            }
-            if i < maxRetries-1 {
-                time.Sleep(time.Duration(i+1) * time.Second) // Exponential backoff
-            }
-            continue
-        }
-        
-        state.conn = conn
-        
-        // Test the connection
-        if err := conn.Ping(); err != nil {
-            conn.Close()
-            state.conn = nil
-            if state.debug {
-                log.Printf("🔄 Reconnect ping failed: %v", err)
-            }
-            continue
-        }
-        
-        if state.debug {
-            log.Printf("✅ Successfully reconnected on attempt %d", i+1)
-        }
-        return nil
-    }
-    
-    return fmt.Errorf("failed to reconnect after %d attempts", maxRetries)
-}
-
-// func monitor(state *AppState) error {
-// 	w, err := mpd.NewWatcher("tcp",
-// 		fmt.Sprintf("%s:%s", state.config.MPD.Host, state.config.MPD.Port),
-// 		"", "player", "mixer")
-// 	if err != nil {
-// 		return fmt.Errorf("failed to create watcher: %v", err)
-// 	}
-// 	defer w.Close()
-
-// 	log.Println("🎵 MPD Monitor started")
-// 	log.Printf("📡 Monitoring: %s:%s", state.config.MPD.Host, state.config.MPD.Port)
-// 	if state.gntpEnabled {
-// 		log.Printf("📢 GNTP Server: %s:%d", state.config.GNTP.Host, state.config.GNTP.Port)
-// 		log.Printf("✅ GNTP registered (icon mode: %s)", state.config.GNTP.IconMode)
-// 	} else {
-// 		log.Println("📢 GNTP/Growl notifications: disabled")
-// 	}
-// 	if state.debug {
-// 		log.Println("🐛 Debug mode: enabled")
-// 	}
-// 	fmt.Println(strings.Repeat("=", getTerminalWidth()))
-
-// 	// Initial status
-// 	if err := checkStatus(state); err != nil {
-// 		if state.debug {
-// 			log.Printf("⚠️  Initial status check failed: %v", err)
-// 		}
-// 	}
-
-// 	// Monitor for errors
-// 	go func() {
-// 		for err := range w.Error {
-// 			if state.debug {
-// 				log.Printf("❌ Watcher error: %v", err)
-// 			}
-// 		}
-// 	}()
-
-// 	// Monitor for events
-// 	for subsystem := range w.Event {
-// 		// Skip database updates to avoid race conditions and bugs
-// 		if subsystem == "database" || subsystem == "update" {
-// 			continue
-// 		}
-
-// 		if err := checkStatus(state); err != nil {
-// 			if state.debug {
-// 				log.Printf("⚠️  Status check failed: %v", err)
-// 			}
-
-// 			// Try to reconnect if connection lost
-// 			if strings.Contains(err.Error(), "EOF") || 
-// 			   strings.Contains(err.Error(), "connection") ||
-// 			   strings.Contains(err.Error(), "broken pipe") {
-				
-// 				if state.debug {
-// 					log.Println("🔄 Attempting to reconnect to MPD...")
-// 				}
-				
-// 				time.Sleep(2 * time.Second)
-				
-// 				if err := reconnectMPD(state); err != nil {
-// 					if state.debug {
-// 						log.Printf("❌ Reconnect failed: %v", err)
-// 					}
-// 					time.Sleep(5 * time.Second)
-// 					continue
-// 				}
-				
-// 				if state.debug {
-// 					log.Println("✅ Reconnected to MPD")
-// 				}
-				
-// 				// Recreate watcher
-// 				w.Close()
-// 				newWatcher, err := mpd.NewWatcher("tcp",
-// 					fmt.Sprintf("%s:%s", state.config.MPD.Host, state.config.MPD.Port),
-// 					"", "player", "mixer")
-// 				if err != nil {
-// 					if state.debug {
-// 						log.Printf("❌ Failed to recreate watcher: %v", err)
-// 					}
-// 					time.Sleep(5 * time.Second)
-// 					continue
-// 				}
-// 				w = newWatcher
-				
-// 				// Restart error monitor
-// 				go func() {
-// 					for err := range w.Error {
-// 						if state.debug {
-// 							log.Printf("❌ Watcher error: %v", err)
-// 						}
-// 					}
-// 				}()
-// 			}
-// 		}
-// 	}
-
-// 	return nil
-// }
-
-// func monitor(state *AppState) error {
-//     w, err := mpd.NewWatcher("tcp",
-//         fmt.Sprintf("%s:%s", state.config.MPD.Host, state.config.MPD.Port),
-//         "", "player", "mixer")
-//     if err != nil {
-//         return fmt.Errorf("failed to create watcher: %v", err)
-//     }
-//     defer w.Close()
-
-//     log.Println("🎵 MPD Monitor started")
-//     log.Printf("📡 Monitoring: %s:%s", state.config.MPD.Host, state.config.MPD.Port)
-//     if state.gntpEnabled {
-//         log.Printf("📢 GNTP Server: %s:%d", state.config.GNTP.Host, state.config.GNTP.Port)
-//         log.Printf("✅ GNTP registered (icon mode: %s)", state.config.GNTP.IconMode)
-//     } else {
-//         log.Println("📢 GNTP/Growl notifications: disabled")
-//     }
-//     if state.debug {
-//         log.Println("🐛 Debug mode: enabled")
-//     }
-//     fmt.Println(strings.Repeat("=", getTerminalWidth()))
-
-//     // Initial status
-//     if err := checkStatus(state); err != nil {
-//         if state.debug {
-//             log.Printf("⚠️  Initial status check failed: %v", err)
-//         }
-//     }
-
-//     // Create a channel to signal when the error monitoring goroutine should stop
-//     stopErrorMonitor := make(chan struct{})
-    
-//     // Monitor for errors with proper cleanup
-//     go func() {
-//         defer func() {
-//             if r := recover(); r != nil && state.debug {
-//                 log.Printf("Recovered from panic in error monitor: %v", r)
-//             }
-//         }()
-        
-//         for {
-//             select {
-//             case err, ok := <-w.Error:
-//                 if !ok {
-//                     // Channel closed, exit goroutine
-//                     return
-//                 }
-//                 if state.debug {
-//                     log.Printf("❌ Watcher error: %v", err)
-//                 }
-//             case <-stopErrorMonitor:
-//                 // Received stop signal
-//                 return
-//             }
-//         }
-//     }()
-
-//     // Monitor for events
-//     for subsystem := range w.Event {
-//         // Skip database updates to avoid race conditions and bugs
-//         if subsystem == "database" || subsystem == "update" {
-//             continue
-//         }
-
-//         if err := checkStatus(state); err != nil {
-//             if state.debug {
-//                 log.Printf("⚠️  Status check failed: %v", err)
-//             }
-
-//             // Try to reconnect if connection lost
-//             if strings.Contains(err.Error(), "EOF") ||
-//                 strings.Contains(err.Error(), "connection") ||
-//                 strings.Contains(err.Error(), "broken pipe") {
-
-//                 if state.debug {
-//                     log.Println("🔄 Attempting to reconnect to MPD...")
-//                 }
-
-//                 // Signal the error monitoring goroutine to stop
-//                 close(stopErrorMonitor)
-                
-//                 time.Sleep(2 * time.Second)
-
-//                 if err := reconnectMPD(state); err != nil {
-//                     if state.debug {
-//                         log.Printf("❌ Reconnect failed: %v", err)
-//                     }
-//                     time.Sleep(5 * time.Second)
-                    
-//                     // Recreate the stop channel for the next iteration
-//                     stopErrorMonitor = make(chan struct{})
-//                     continue
-//                 }
-
-//                 if state.debug {
-//                     log.Println("✅ Reconnected to MPD")
-//                 }
-
-//                 // Recreate watcher
-//                 w.Close()
-//                 newWatcher, err := mpd.NewWatcher("tcp",
-//                     fmt.Sprintf("%s:%s", state.config.MPD.Host, state.config.MPD.Port),
-//                     "", "player", "mixer")
-//                 if err != nil {
-//                     if state.debug {
-//                         log.Printf("❌ Failed to recreate watcher: %v", err)
-//                     }
-//                     time.Sleep(5 * time.Second)
-                    
-//                     // Recreate the stop channel for the next iteration
-//                     stopErrorMonitor = make(chan struct{})
-//                     continue
-//                 }
-//                 w = newWatcher
-
-//                 // Restart error monitor with new watcher
-//                 stopErrorMonitor = make(chan struct{})
-//                 go func() {
-//                     defer func() {
-//                         if r := recover(); r != nil && state.debug {
-//                             log.Printf("Recovered from panic in error monitor: %v", r)
-//                         }
-//                     }()
-                    
-//                     for {
-//                         select {
-//                         case err, ok := <-w.Error:
-//                             if !ok {
-//                                 return
-//                             }
-//                             if state.debug {
-//                                 log.Printf("❌ Watcher error: %v", err)
-//                             }
-//                         case <-stopErrorMonitor:
-//                             return
-//                         }
-//                     }
-//                 }()
-//             }
-//         }
-//     }
-
-//     return nil
-// }
-
-func monitor(state *AppState) error {
-    log.Println("🎵 MPD Monitor started")
-    log.Printf("📡 Monitoring: %s:%s", state.config.MPD.Host, state.config.MPD.Port)
-    if state.gntpEnabled {
-        log.Printf("📢 GNTP Server: %s:%d", state.config.GNTP.Host, state.config.GNTP.Port)
-        log.Printf("✅ GNTP registered (icon mode: %s)", state.config.GNTP.IconMode)
-    } else {
-        log.Println("📢 GNTP/Growl notifications: disabled")
-    }
-    if state.debug {
-        log.Println("🐛 Debug mode: enabled")
-    }
-    fmt.Println(strings.Repeat("=", getTerminalWidth()))
-
-    // Initial status
-    if err := checkStatus(state); err != nil {
-        if state.debug {
-            log.Printf("⚠️  Initial status check failed: %v", err)
-        }
-    }
-
-    // Main monitoring loop with reconnection
-    for {
-        err := monitorOnce(state)
-        if err != nil {
-            if state.debug {
-                log.Printf("❌ Monitor error: %v", err)
-            }
-            
-            // Check if it's a connection error that warrants reconnection
-            if strings.Contains(err.Error(), "EOF") ||
-                strings.Contains(err.Error(), "connection") ||
-                strings.Contains(err.Error(), "broken pipe") ||
-                strings.Contains(err.Error(), "watcher") {
-                
-                if state.debug {
-                    log.Println("🔄 Attempting to reconnect to MPD...")
-                }
-                
-                time.Sleep(2 * time.Second)
-                
-                // Try to reconnect MPD
-                if err := reconnectMPD(state); err != nil {
-                    if state.debug {
-                        log.Printf("❌ Reconnect failed: %v", err)
-                    }
-                    time.Sleep(5 * time.Second)
-                    continue
-                }
-                
-                if state.debug {
-                    log.Println("✅ Reconnected to MPD")
-                }
-                
-                // Continue the loop to create new watcher
-                continue
-            }
-            
-            // If it's not a connection error, return it
-            return err
-        }
-        
-        // If monitorOnce returns without error, it means we should reconnect
-        if state.debug {
-            log.Println("📡 Connection lost, attempting to reconnect...")
-        }
-        time.Sleep(2 * time.Second)
-    }
-}
-
-func monitorOnce(state *AppState) error {
-    // Create a new watcher
-    w, err := mpd.NewWatcher("tcp",
-        fmt.Sprintf("%s:%s", state.config.MPD.Host, state.config.MPD.Port),
-        "", "player", "mixer")
-    if err != nil {
-        return fmt.Errorf("failed to create watcher: %v", err)
-    }
-    
-    // Create a done channel to signal when monitoring should stop
-    done := make(chan struct{})
-    defer close(done)
-    
-    // Error handling goroutine with panic recovery
-    go func() {
-        defer func() {
-            if r := recover(); r != nil && state.debug {
-                log.Printf("🛡️  Recovered from panic in error monitor: %v", r)
-            }
-        }()
-        
-        for {
-            select {
-            case err, ok := <-w.Error:
-                if !ok {
-                    // Channel closed, exit goroutine
-                    return
-                }
-                if state.debug {
-                    log.Printf("⚠️  Watcher error: %v", err)
-                }
-            case <-done:
-                // Monitoring stopped, exit goroutine
-                return
-            }
-        }
-    }()
-    
-    // Main event monitoring loop
-    for {
-        select {
-        case subsystem, ok := <-w.Event:
-            if !ok {
-                // Event channel closed, return to trigger reconnection
-                w.Close()
-                return fmt.Errorf("watcher event channel closed")
-            }
-            
-            // Skip database updates to avoid race conditions and bugs
-            if subsystem == "database" || subsystem == "update" {
-                continue
-            }
-            
-            if err := checkStatus(state); err != nil {
-                if state.debug {
-                    log.Printf("⚠️  Status check failed: %v", err)
-                }
-                
-                // If checkStatus fails with a connection error, close watcher and return
-                if strings.Contains(err.Error(), "EOF") ||
-                    strings.Contains(err.Error(), "connection") ||
-                    strings.Contains(err.Error(), "broken pipe") {
-                    w.Close()
-                    return err
-                }
-            }
-            
-        case <-done:
-            // Monitoring stopped, close watcher and return
-            w.Close()
-            return nil
-            
-        case <-time.After(30 * time.Second):
-            // Periodic status check to ensure we're still connected
-            if err := state.conn.Ping(); err != nil {
-                if state.debug {
-                    log.Printf("⚠️  Ping failed: %v", err)
-                }
-                w.Close()
-                return fmt.Errorf("ping failed: %v", err)
-            }
-        }
-    }
-}
-
-// func checkStatus(state *AppState) error {
-// 	status, err := state.conn.Status()
-// 	if err != nil {
-// 		return fmt.Errorf("failed to get status: %v", err)
-// 	}
-
-// 	currentState := status["state"]
-
-// 	// Get current song
-// 	song, err := state.conn.CurrentSong()
-// 	if err != nil {
-// 		return fmt.Errorf("failed to get current song: %v", err)
-// 	}
-
-// 	currentFile := song["file"]
-
-// 	// Check if song changed or state changed
-// 	songChanged := currentFile != state.lastSongFile && currentFile != ""
-// 	stateChanged := currentState != state.lastState && state.lastState != "" // Only if we have previous state
-
-// 	// Display current status
-// 	if currentState == "play" && currentFile != "" {
-// 		info := formatConsolePlaying(song, status)
-// 		fmt.Println()
-// 		fmt.Println(info)
-// 		printSeparator()
-// 	} else if stateChanged {
-// 		fmt.Printf("⏸  State: %s\n", currentState)
-// 		printSeparator()
-// 	}
-
-// 	// Send notification for song change
-// 	if songChanged && currentState == "play" {
-// 		artwork := getAlbumArt(state.conn, currentFile)
-
-// 		title := song["Title"]
-// 		if title == "" {
-// 			title = currentFile
-// 		}
-
-// 		message := formatCurrentPlaying(song, status)
-
-// 		if err := sendNotification(state, "song_change", title, message, artwork); err != nil {
-// 			if state.debug {
-// 				log.Printf("⚠️  Failed to send notification: %v", err)
-// 			}
-// 		} //else if state.gntpEnabled {
-// 		// 	fmt.Println("📢 Notification sent")
-// 		// }
-
-// 		state.lastSongFile = currentFile
-// 	}
-
-// 	// Send notification for state change (play, stop, pause)
-// 	if stateChanged {
-// 		var stateMsg string
-// 		switch currentState {
-// 		case "play":
-// 			stateMsg = "▶ Playing"
-// 		case "pause":
-// 			stateMsg = "⏸ Paused"
-// 		case "stop":
-// 			stateMsg = "⏹ Stopped"
-// 		default:
-// 			stateMsg = fmt.Sprintf("State: %s", currentState)
-// 		}
-
-// 		var artwork *gntp.Resource
-// 		if currentFile != "" {
-// 			artwork = getAlbumArt(state.conn, currentFile)
-// 		}
-
-// 		message := stateMsg
-// 		if currentState == "play" && currentFile != "" {
-// 			message = formatCurrentPlaying(song, status)
-// 		}
-
-// 		if err := sendNotification(state, "player_state", stateMsg, message, artwork); err != nil {
-// 			if state.debug {
-// 				log.Printf("⚠️  Failed to send notification: %v", err)
-// 			}
-// 		} else if state.gntpEnabled {
-// 			fmt.Println("📢 State notification sent")
-// 		}
-// 	}
-
-// 	state.lastState = currentState
-
-// 	return nil
-// }
-
-func checkStatus(state *AppState) error {
-    // First, ping to check connection
-    if err := state.conn.Ping(); err != nil {
-        return fmt.Errorf("connection lost: %v", err)
-    }
-    
-    status, err := state.conn.Status()
-    if err != nil {
-        return fmt.Errorf("failed to get status: %v", err)
-    }
-
-    currentState := status["state"]
-
-    // Get current song
-    song, err := state.conn.CurrentSong()
-    if err != nil {
-        return fmt.Errorf("failed to get current song: %v", err)
-    }
-
-    currentFile := song["file"]
-
-    // Check if song changed or state changed
-    songChanged := currentFile != state.lastSongFile && currentFile != ""
-    stateChanged := currentState != state.lastState && state.lastState != "" // Only if we have previous state
-
-    // Display current status
-    if currentState == "play" && currentFile != "" {
-        info := formatConsolePlaying(song, status)
-        fmt.Println()
-        fmt.Println(info)
-        printSeparator()
-    } else if stateChanged {
-        fmt.Printf("⏸  State: %s\n", currentState)
-        printSeparator()
-    }
-
-    // Send notification for song change
-    if songChanged && currentState == "play" {
-        artwork := getAlbumArt(state.conn, currentFile)
-
-        title := song["Title"]
-        if title == "" {
-            title = currentFile
-        }
-
-        message := formatCurrentPlaying(song, status)
-
-        if err := sendNotification(state, "song_change", title, message, artwork); err != nil {
-            if state.debug {
-                log.Printf("⚠️  Failed to send notification: %v", err)
-            }
-        }
-
-        state.lastSongFile = currentFile
-    }
-
-    // Send notification for state change (play, stop, pause)
-    if stateChanged {
-        var stateMsg string
-        switch currentState {
-        case "play":
-            stateMsg = "▶ Playing"
-        case "pause":
-            stateMsg = "⏸ Paused"
-        case "stop":
-            stateMsg = "⏹ Stopped"
-        default:
-            stateMsg = fmt.Sprintf("State: %s", currentState)
-        }
-
-        var artwork *gntp.Resource
-        if currentFile != "" {
-            artwork = getAlbumArt(state.conn, currentFile)
-        }
-
-        message := stateMsg
-        if currentState == "play" && currentFile != "" {
-            message = formatCurrentPlaying(song, status)
-        }
-
-        if err := sendNotification(state, "player_state", stateMsg, message, artwork); err != nil {
-            if state.debug {
-                log.Printf("⚠️  Failed to send notification: %v", err)
-            }
-        } //else if state.gntpEnabled {
-        //     fmt.Println("📢 State notification sent")
-        // }
-    }
-
-    state.lastState = currentState
-
-    return nil
+	return def
 }
 
 func main() {
@@ -958,21 +299,23 @@ func main() {
 		configFile string
 		mpdHost    string
 		mpdPort    string
+		mpdPass    string
 		mpdTimeout int
 		gntpHost   string
 		gntpPort   int
-		gntpPass   string
 		iconMode   string
+		server     string
 	)
 
 	flag.StringVar(&configFile, "config", "", "Path to TOML config file")
-	flag.StringVar(&mpdHost, "mpd-host", "", "MPD host (default: localhost or MPD_HOST env)")
+	flag.StringVar(&mpdHost, "mpd-host", "", "MPD host, socket path (/...) or abstract socket (@...), optionally password@host (default: local socket, localhost or MPD_HOST env)")
 	flag.StringVar(&mpdPort, "mpd-port", "", "MPD port (default: 6600 or MPD_PORT env)")
-	flag.IntVar(&mpdTimeout, "mpd-timeout", 0, "MPD timeout in seconds (default: 10 or MPD_TIMEOUT env)")
+	flag.StringVar(&mpdPass, "mpd-password", "", "MPD password (default: from password@host or config)")
+	flag.IntVar(&mpdTimeout, "mpd-timeout", 0, "MPD dial and command timeout in seconds (default: 10 or MPD_TIMEOUT env)")
 	flag.StringVar(&gntpHost, "gntp-host", "", "GNTP/Growl host (default: localhost)")
 	flag.IntVar(&gntpPort, "gntp-port", 0, "GNTP/Growl port (default: 23053)")
-	flag.StringVar(&gntpPass, "gntp-password", "", "GNTP/Growl password")
 	flag.StringVar(&iconMode, "icon-mode", "", "Icon mode: binary, dataurl, fileurl, httpurl (default: binary)")
+	flag.StringVar(&server, "server", "", "MPD server name for subcommands (default: the first)")
 
 	flag.Parse()
 
@@ -985,8 +328,7 @@ func main() {
 		log.Fatalf("❌ Failed to load config: %v", err)
 	}
 
-	// Override with environment variables
-	config.MPD.Host = getEnvOrDefault("MPD_HOST", config.MPD.Host)
+	mpdHostOverrides(&config, os.Getenv("MPD_HOST"), mpdHost, mpdPass)
 	config.MPD.Port = getEnvOrDefault("MPD_PORT", config.MPD.Port)
 
 	if timeoutStr := os.Getenv("MPD_TIMEOUT"); timeoutStr != "" {
@@ -996,48 +338,91 @@ func main() {
 	}
 
 	// Override with command line arguments
-	if mpdHost != "" {
-		config.MPD.Host = mpdHost
-	}
 	if mpdPort != "" {
 		config.MPD.Port = mpdPort
 	}
 	if mpdTimeout > 0 {
 		config.MPD.Timeout = mpdTimeout
 	}
-	if gntpHost != "" {
-		config.GNTP.Host = gntpHost
+	gntpFlags := func(cfg *Config) {
+		if gntpHost != "" {
+			cfg.GNTP.Host = gntpHost
+		}
+		if gntpPort > 0 {
+			cfg.GNTP.Port = gntpPort
+		}
+		if iconMode != "" {
+			cfg.GNTP.IconMode = iconMode
+		}
 	}
-	if gntpPort > 0 {
-		config.GNTP.Port = gntpPort
+	gntpFlags(&config)
+
+	configs := monitorConfigs(config, debug)
+	mon, err := monitor.NewGroup(configs...)
+	if err != nil {
+		log.Fatalf("❌ Invalid MPD servers: %v", err)
 	}
-	if gntpPass != "" {
-		config.GNTP.Password = gntpPass
+
+	// Subcommands
+	switch flag.Arg(0) {
+	case "":
+	case "outputs":
+		if err := runOutputs(configs, server, flag.Args()[1:]); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	default:
+		log.Fatalf("❌ Unknown command %q", flag.Arg(0))
 	}
-	if iconMode != "" {
-		config.GNTP.IconMode = iconMode
+
+	log.Println("🎵 MPD Monitor started")
+	for _, m := range mon.Monitors() {
+		if source := m.Source(); source != "" {
+			log.Printf("📡 Monitoring %s: %s", source, m.Addr())
+		} else {
+			log.Printf("📡 Monitoring: %s", m.Addr())
+		}
 	}
 
-	// Connect to MPD
-	conn, err := connectMPD(config.MPD.Host, config.MPD.Port, config.MPD.Timeout)
-	if err != nil {
-		log.Fatalf("❌ %v", err)
+	if config.MPD.Channel != "" {
+		log.Printf("💬 Listening for commands on channel %s", config.MPD.Channel)
 	}
-	defer conn.Close()
 
-	// Setup GNTP (optional - don't fail if not available)
-	gntpClient, gntpEnabled := setupGNTP(config, debug)
+	// Setup notifiers; the reload command sets them up again
+	ctl := &controller{debug: debug}
+	ctl.notifiers = buildNotifiers(config, mon, ctl.open, debug)
+	ctl.reload = func() (notifier.Notifier, error) {
+		cfg, err := loadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		gntpFlags(&cfg)
+		return buildNotifiers(cfg, mon, ctl.open, debug), nil
+	}
+	defer ctl.Close()
+	if debug {
+		log.Println("🐛 Debug mode: enabled")
+	}
+	fmt.Println(strings.Repeat("=", render.TerminalWidth()))
+
+	// Console output and notifications both consume the event stream
+	printer := render.NewPrinter(os.Stdout)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range mon.Events() {
+			printer.Handle(ev)
+			ctl.Handle(ev)
+		}
+	}()
 
-	state := &AppState{
-		conn:        conn,
-		gntp:        gntpClient,
-		config:      config,
-		debug:       debug,
-		gntpEnabled: gntpEnabled,
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start monitoring
-	if err := monitor(state); err != nil {
+	err = mon.Run(ctx)
+	<-done
+	if err != nil {
 		log.Fatalf("❌ Monitor error: %v", err)
 	}
-}
\ No newline at end of file
+}
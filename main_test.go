@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestMPDHostOverrides(t *testing.T) {
+	tests := []struct {
+		name                    string
+		host, password          string // from the config file
+		env, flagHost, flagPass string
+		wantHost, wantPassword  string
+	}{
+		{"config only", "mpd.lan", "cfg", "", "", "", "mpd.lan", "cfg"},
+		{"password@host in config", "pw@mpd.lan", "cfg", "", "", "", "mpd.lan", "pw"},
+		{"MPD_HOST over config", "mpd.lan", "cfg", "env@other", "", "", "other", "env"},
+		{"MPD_HOST without password", "mpd.lan", "cfg", "other", "", "", "other", "cfg"},
+		{"abstract socket in MPD_HOST", "mpd.lan", "", "@mpd", "", "", "@mpd", ""},
+		{"flag over MPD_HOST", "", "", "env@other", "flag@third", "", "third", "flag"},
+		{"flag without password", "", "", "env@other", "/run/mpd/socket", "", "/run/mpd/socket", "env"},
+		{"password flag over all", "pw@mpd.lan", "", "env@other", "flag@third", "secret", "third", "secret"},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		cfg.MPD.Host, cfg.MPD.Password = tt.host, tt.password
+		mpdHostOverrides(&cfg, tt.env, tt.flagHost, tt.flagPass)
+		if cfg.MPD.Host != tt.wantHost || cfg.MPD.Password != tt.wantPassword {
+			t.Errorf("%s: host %q, password %q; want %q, %q", tt.name, cfg.MPD.Host, cfg.MPD.Password, tt.wantHost, tt.wantPassword)
+		}
+	}
+}
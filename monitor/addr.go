@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseHost splits an MPD_HOST style value of the form password@host into
+// its parts, as mpc and ncmpcpp do. A leading @ denotes an abstract socket
+// name rather than an empty password.
+func ParseHost(value string) (host, password string) {
+	if i := strings.Index(value, "@"); i > 0 {
+		return value[i+1:], value[:i]
+	}
+	return value, ""
+}
+
+// ParseAddress splits an address of the form [password@]host[:port] into
+// its parts. Socket paths and abstract socket names are returned as the
+// host with an empty port.
+func ParseAddress(value string) (host, port, password string) {
+	host, password = ParseHost(value)
+	if strings.HasPrefix(host, "/") || strings.HasPrefix(host, "@") {
+		return host, "", password
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		return h, p, password
+	}
+	return host, "", password
+}
+
+// Endpoint returns the network and address to dial. Hosts starting with /
+// are Unix socket paths and hosts starting with @ are abstract sockets,
+// which Go dials as "unix" with the @ kept. An empty host is resolved with
+// DiscoverSocket, falling back to localhost.
+func (c Config) Endpoint() (network, addr string) {
+	host := c.Host
+	if host == "" {
+		if path := DiscoverSocket(); path != "" {
+			return "unix", path
+		}
+		host = "localhost"
+	}
+
+	if strings.HasPrefix(host, "/") || strings.HasPrefix(host, "@") {
+		return "unix", host
+	}
+
+	port := c.Port
+	if port == "" {
+		port = "6600"
+	}
+	return "tcp", net.JoinHostPort(host, port)
+}
+
+// DiscoverSocket returns the first existing MPD socket among
+// $XDG_RUNTIME_DIR/mpd/socket, ~/.local/run/mpd/socket and
+// /run/mpd/socket, or "" if there is none.
+func DiscoverSocket() string {
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "mpd", "socket"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".local", "run", "mpd", "socket"))
+	}
+	candidates = append(candidates, "/run/mpd/socket")
+
+	for _, path := range candidates {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package monitor_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/mpdtest"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in                   string
+		host, port, password string
+	}{
+		{"localhost", "localhost", "", ""},
+		{"mpd.lan:6601", "mpd.lan", "6601", ""},
+		{"pw@host", "host", "", "pw"},
+		{"pw@host:6601", "host", "6601", "pw"},
+		{"pw@/run/mpd/socket", "/run/mpd/socket", "", "pw"},
+		{"/run/mpd/socket", "/run/mpd/socket", "", ""},
+		{"@abstract", "@abstract", "", ""},
+		{"pw@@abstract", "@abstract", "", "pw"},
+		{"[::1]:6600", "::1", "6600", ""},
+		{"pw@[::1]:6600", "::1", "6600", "pw"},
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		host, port, password := monitor.ParseAddress(tt.in)
+		if host != tt.host || port != tt.port || password != tt.password {
+			t.Errorf("ParseAddress(%q) = %q, %q, %q; want %q, %q, %q", tt.in, host, port, password, tt.host, tt.port, tt.password)
+		}
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		in, host, password string
+	}{
+		{"pw@host", "host", "pw"},
+		{"pw@host:6601", "host:6601", "pw"},
+		{"pw@/run/mpd/socket", "/run/mpd/socket", "pw"},
+		{"@abstract", "@abstract", ""},
+		{"pw@@abstract", "@abstract", "pw"},
+		{"[::1]:6600", "[::1]:6600", ""},
+	}
+	for _, tt := range tests {
+		host, password := monitor.ParseHost(tt.in)
+		if host != tt.host || password != tt.password {
+			t.Errorf("ParseHost(%q) = %q, %q; want %q, %q", tt.in, host, password, tt.host, tt.password)
+		}
+	}
+}
+
+// runtimeDir points XDG_RUNTIME_DIR and HOME at fresh directories and
+// returns where DiscoverSocket looks for the socket first.
+func runtimeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.Mkdir(filepath.Join(dir, "mpd"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "mpd", "socket")
+}
+
+func TestDiscoverSocket(t *testing.T) {
+	path := runtimeDir(t)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if got := monitor.DiscoverSocket(); got != path {
+		t.Errorf("DiscoverSocket() = %q, want %q", got, path)
+	}
+	if network, addr := (monitor.Config{}).Endpoint(); network != "unix" || addr != path {
+		t.Errorf("Endpoint() = %s %s, want unix %s", network, addr, path)
+	}
+}
+
+func TestDiscoverSocketSkipsFiles(t *testing.T) {
+	path := runtimeDir(t)
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := monitor.DiscoverSocket(); got == path {
+		t.Errorf("DiscoverSocket() = %q, a plain file", got)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		host, port    string
+		network, addr string
+	}{
+		{"mpd.lan", "", "tcp", "mpd.lan:6600"},
+		{"mpd.lan", "6601", "tcp", "mpd.lan:6601"},
+		{"::1", "6600", "tcp", "[::1]:6600"},
+		{"/run/mpd/socket", "6600", "unix", "/run/mpd/socket"},
+		{"@mpd", "", "unix", "@mpd"},
+	}
+	for _, tt := range tests {
+		network, addr := monitor.Config{Host: tt.host, Port: tt.port}.Endpoint()
+		if network != tt.network || addr != tt.addr {
+			t.Errorf("Endpoint() for %q, %q = %s %s, want %s %s", tt.host, tt.port, network, addr, tt.network, tt.addr)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	srv, err := mpdtest.NewUnixServer(filepath.Join(t.TempDir(), "socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	waitFor(t, events, monitor.KindSongChanged)
+}
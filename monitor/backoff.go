@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ReconnectPolicy controls the delays between reconnect attempts. Each
+// delay is the previous one times Multiplier, capped at MaxDelay, and then
+// moved randomly by up to Jitter (a fraction of the delay) so that several
+// monitors do not hammer a restarted MPD in lockstep.
+type ReconnectPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64 // 0..1
+	MaxAttempts  int     // 0 retries forever
+}
+
+// DefaultReconnectPolicy retries forever, starting after one second and
+// backing off to one minute.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialDelay: time.Second,
+		MaxDelay:     time.Minute,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// withDefaults fills unset fields from DefaultReconnectPolicy.
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	def := DefaultReconnectPolicy()
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = def.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.MaxAttempts < 0 {
+		p.MaxAttempts = 0
+	}
+	return p
+}
+
+// BackoffState describes where the monitor is in its reconnect cycle.
+type BackoffState struct {
+	Reconnecting bool
+	Attempt      int           // attempts made in the current cycle
+	MaxAttempts  int           // 0 means unlimited
+	Delay        time.Duration // wait before the latest attempt
+	LastError    error         // why the connection was lost or the last attempt failed
+}
+
+// String formats the state for log and console lines, e.g. "attempt 3/∞,
+// next in 4.1s".
+func (s BackoffState) String() string {
+	if !s.Reconnecting {
+		return "connected"
+	}
+	max := "∞"
+	if s.MaxAttempts > 0 {
+		max = fmt.Sprint(s.MaxAttempts)
+	}
+	return fmt.Sprintf("attempt %d/%s, next in %v", s.Attempt, max, s.Delay.Round(100*time.Millisecond))
+}
+
+// backoff computes successive reconnect delays. It is safe for concurrent
+// use so that State can be read while the monitor reconnects.
+type backoff struct {
+	policy ReconnectPolicy
+
+	mu    sync.Mutex
+	delay time.Duration // un-jittered delay of the next attempt
+	state BackoffState
+}
+
+func newBackoff(policy ReconnectPolicy) *backoff {
+	policy = policy.withDefaults()
+	return &backoff{
+		policy: policy,
+		delay:  policy.InitialDelay,
+		state:  BackoffState{MaxAttempts: policy.MaxAttempts},
+	}
+}
+
+// next records a failure and returns how long to wait before the next
+// attempt. It reports false once MaxAttempts is exhausted.
+func (b *backoff) next(cause error) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state.Reconnecting = true
+	b.state.LastError = cause
+	if b.policy.MaxAttempts > 0 && b.state.Attempt >= b.policy.MaxAttempts {
+		return 0, false
+	}
+
+	d := b.delay
+	if b.policy.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + b.policy.Jitter*(2*rand.Float64()-1)))
+	}
+
+	b.delay = time.Duration(float64(b.delay) * b.policy.Multiplier)
+	if b.delay > b.policy.MaxDelay {
+		b.delay = b.policy.MaxDelay
+	}
+
+	b.state.Attempt++
+	b.state.Delay = d
+	return d, true
+}
+
+// reset starts a fresh cycle after a successful connection.
+func (b *backoff) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.delay = b.policy.InitialDelay
+	b.state = BackoffState{MaxAttempts: b.policy.MaxAttempts}
+}
+
+func (b *backoff) current() BackoffState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
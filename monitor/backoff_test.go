@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errLost = errors.New("connection lost")
+
+func TestBackoffGrowsToMaxDelay(t *testing.T) {
+	b := newBackoff(ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2})
+
+	for i, want := range []time.Duration{1, 2, 4, 5, 5} {
+		d, ok := b.next(errLost)
+		if !ok || d != want*time.Second {
+			t.Errorf("attempt %d: delay %v, %t; want %v, true", i+1, d, ok, want*time.Second)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Second, Multiplier: 1, Jitter: 0.2}
+	b := newBackoff(policy)
+
+	min, max := 800*time.Millisecond, 1200*time.Millisecond
+	varied := false
+	for range 200 {
+		d, _ := b.next(errLost)
+		if d < min || d > max {
+			t.Fatalf("delay %v outside [%v, %v]", d, min, max)
+		}
+		varied = varied || d != time.Second
+	}
+	if !varied {
+		t.Error("jitter never moved the delay")
+	}
+}
+
+func TestBackoffMaxAttempts(t *testing.T) {
+	b := newBackoff(ReconnectPolicy{InitialDelay: time.Millisecond, MaxAttempts: 3})
+
+	for i := range 3 {
+		if _, ok := b.next(errLost); !ok {
+			t.Fatalf("attempt %d refused", i+1)
+		}
+	}
+	if _, ok := b.next(errLost); ok {
+		t.Error("a fourth attempt was allowed with MaxAttempts 3")
+	}
+	if s := b.current(); s.Attempt != 3 || !s.Reconnecting || s.LastError != errLost {
+		t.Errorf("state = %+v, want attempt 3 reconnecting after %v", s, errLost)
+	}
+}
+
+func TestBackoffReset(t *testing.T) {
+	b := newBackoff(ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 3, MaxAttempts: 2})
+	b.next(errLost)
+	b.next(errLost)
+
+	b.reset()
+	if s := b.current(); s.Reconnecting || s.Attempt != 0 || s.LastError != nil {
+		t.Errorf("state after reset = %+v", s)
+	}
+	d, ok := b.next(errLost)
+	if !ok || d != time.Second {
+		t.Errorf("first delay after reset = %v, %t; want 1s, true", d, ok)
+	}
+}
+
+func TestReconnectPolicyDefaults(t *testing.T) {
+	def := DefaultReconnectPolicy()
+	tests := []struct {
+		name   string
+		policy ReconnectPolicy
+		want   ReconnectPolicy
+	}{
+		{
+			// Jitter 0 turns jitter off rather than asking for the default
+			"zero", ReconnectPolicy{},
+			ReconnectPolicy{InitialDelay: def.InitialDelay, MaxDelay: def.MaxDelay, Multiplier: def.Multiplier},
+		},
+		{
+			"max below initial",
+			ReconnectPolicy{InitialDelay: 10 * time.Second, MaxDelay: time.Second, Multiplier: 2},
+			ReconnectPolicy{InitialDelay: 10 * time.Second, MaxDelay: 10 * time.Second, Multiplier: 2},
+		},
+		{
+			"jitter above 1",
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 3},
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 1},
+		},
+		{
+			"negative jitter and attempts",
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: -1, MaxAttempts: -5},
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2},
+		},
+		{
+			"shrinking multiplier",
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 0.5},
+			ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: def.Multiplier},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.withDefaults(); got != tt.want {
+			t.Errorf("%s: withDefaults() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffStateString(t *testing.T) {
+	tests := []struct {
+		state BackoffState
+		want  string
+	}{
+		{BackoffState{}, "connected"},
+		{BackoffState{Reconnecting: true, Attempt: 3, Delay: 4120 * time.Millisecond}, "attempt 3/∞, next in 4.1s"},
+		{BackoffState{Reconnecting: true, Attempt: 1, MaxAttempts: 5, Delay: time.Second}, "attempt 1/5, next in 1s"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("%+v: String() = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
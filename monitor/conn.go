@@ -0,0 +1,442 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// ErrTimeout is returned when MPD does not answer within Config.Timeout.
+var ErrTimeout = mpdproto.ErrTimeout
+
+// subsystems are the idle subsystems the monitor waits on.
+var subsystems = []string{"player", "mixer", "options", "playlist", "update", "database", "output"}
+
+// idleSubsystems returns subsystems without the database ones under
+// NoDatabase, plus "message" with a Channel.
+func (c Config) idleSubsystems() []string {
+	list := slices.Clone(subsystems)
+	if c.NoDatabase {
+		list = slices.DeleteFunc(list, isDatabaseSubsystem)
+	}
+	if c.Channel != "" {
+		list = append(list, "message")
+	}
+	return list
+}
+
+func isDatabaseSubsystem(name string) bool {
+	return name == "update" || name == "database"
+}
+
+// pingInterval is how long the connection may sit in idle before the
+// monitor interrupts it to make sure MPD is still there. Any other round
+// trip proves that as well and restarts the interval.
+const pingInterval = 30 * time.Second
+
+// requestWait bounds how long a request waits for the connection loop
+// when Config.Timeout is zero.
+const requestWait = 10 * time.Second
+
+// timeout returns the configured per-call limit, or 0 for none.
+func (c Config) timeout() time.Duration {
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// request is a piece of work run on the connection between two idles.
+type request struct {
+	fn   func(*mpdproto.Conn) error
+	done chan error
+}
+
+// idleResult is the outcome of one idle.
+type idleResult struct {
+	changed []string
+	err     error
+}
+
+// do runs fn on the monitor's connection and returns its error. It fails
+// with ErrDisconnected if the connection loop does not pick the request up
+// in time, e.g. while Run is reconnecting. Under Config.Direct fn gets a
+// connection of its own.
+func (m *Monitor) do(fn func(*mpdproto.Conn) error) error {
+	if m.config.Direct {
+		conn, err := m.connect()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return fn(conn)
+	}
+
+	wait := m.config.timeout()
+	if wait <= 0 {
+		wait = requestWait
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	req := request{fn: fn, done: make(chan error, 1)}
+	select {
+	case m.requests <- req:
+	case <-t.C:
+		return fmt.Errorf("no connection to run the request on: %w", ErrDisconnected)
+	}
+	return <-req.done
+}
+
+// session drives one connection until it fails or ctx is cancelled. The
+// connection sits in idle; a change, a request or the ping interval ends
+// the idle, after which the monitor queries MPD and idles again.
+func (m *Monitor) session(ctx context.Context, conn *mpdproto.Conn) error {
+	// The connection is up, so the next failure starts a fresh cycle
+	m.backoff.reset()
+
+	// The queue version may have restarted along with MPD
+	m.queue = queue{}
+	m.outputs = nil
+
+	// Catch up on anything that changed while we were not watching
+	if err := m.check(ctx, conn, time.Now(), nil); err != nil {
+		if m.config.Debug {
+			log.Printf("⚠️  Initial status check failed: %v", err)
+		}
+		if isConnectionError(err) {
+			return err
+		}
+	}
+
+	for {
+		if err := conn.BeginIdle(m.config.idleSubsystems()...); err != nil {
+			return fmt.Errorf("failed to enter idle: %w", err)
+		}
+
+		idle := make(chan idleResult, 1)
+		go func() {
+			changed, err := conn.ReadIdle()
+			idle <- idleResult{changed, err}
+		}()
+
+		// Wake up early if the next song is due to be announced
+		wait := pingInterval
+		if at := m.upNextAt(m.last); !at.IsZero() && time.Until(at) < wait {
+			wait = max(time.Until(at), 0)
+		}
+		ping := time.NewTimer(wait)
+		var pending []request
+		var res idleResult
+		select {
+		case res = <-idle:
+		case req := <-m.requests:
+			pending = append(pending, req)
+			res = interrupt(conn, idle)
+		case <-ping.C:
+			// The noidle round trip doubles as a liveness check
+			res = interrupt(conn, idle)
+		case <-ctx.Done():
+			ping.Stop()
+			return nil
+		}
+		ping.Stop()
+		woke := time.Now()
+
+		if res.err != nil {
+			err := fmt.Errorf("idle failed: %w", res.err)
+			for _, req := range pending {
+				req.done <- err
+			}
+			return err
+		}
+
+		if len(res.changed) > 0 {
+			if err := m.check(ctx, conn, woke, res.changed); err != nil {
+				if m.config.Debug {
+					log.Printf("⚠️  Status check failed: %v", err)
+				}
+
+				// ACKs such as permission denied leave the connection usable
+				if isConnectionError(err) {
+					for _, req := range pending {
+						req.done <- err
+					}
+					return err
+				}
+			}
+		}
+
+		if err := m.serve(conn, pending); err != nil {
+			return err
+		}
+
+		if err := m.announceNext(ctx, conn, time.Now()); err != nil {
+			if isConnectionError(err) {
+				return err
+			}
+			if m.config.Debug {
+				log.Printf("⚠️  %v", err)
+			}
+		}
+	}
+}
+
+// interrupt ends the current idle and waits for its result.
+func interrupt(conn *mpdproto.Conn, idle <-chan idleResult) idleResult {
+	if err := conn.NoIdle(); err != nil {
+		// Unblock the reader; it then reports the broken connection
+		conn.Close()
+	}
+	return <-idle
+}
+
+// serve runs pending and any further queued requests. It returns the first
+// connection error, after which the connection must not be used.
+func (m *Monitor) serve(conn *mpdproto.Conn, pending []request) error {
+	for {
+		if len(pending) == 0 {
+			select {
+			case req := <-m.requests:
+				pending = append(pending, req)
+			default:
+				return nil
+			}
+		}
+
+		req := pending[0]
+		pending = pending[1:]
+
+		err := req.fn(conn)
+		req.done <- err
+		if isConnectionError(err) {
+			for _, req := range pending {
+				req.done <- err
+			}
+			return err
+		}
+	}
+}
+
+// check queries the current status and emits events for whatever changed
+// since the previous check. since is when MPD reported the change and
+// changed lists the idle subsystems involved.
+func (m *Monitor) check(ctx context.Context, conn *mpdproto.Conn, since time.Time, changed []string) error {
+	cur, err := snapshot(conn)
+	if err != nil {
+		m.emit(ctx, ErrorRaised{Header: Header{Current: m.last}, Err: err})
+		return err
+	}
+	cur.Latency = cur.Time.Sub(since)
+	cur.Server = m.config.Name
+	cur.Partition = m.config.Partition
+
+	events := diff(m.last, cur)
+	queueEvents, err := m.queue.update(conn, cur)
+	if err != nil {
+		if isConnectionError(err) {
+			return err
+		}
+		if m.config.Debug {
+			log.Printf("⚠️  Queue update failed: %v", err)
+		}
+	}
+	events = append(events, queueEvents...)
+	if m.config.NoDatabase {
+		// updating_db shows in every partition's status
+		events = slices.DeleteFunc(events, func(ev Event) bool {
+			kind := ev.Kind()
+			return kind == KindUpdateStarted || kind == KindUpdateFinished
+		})
+	} else {
+		events = append(events, m.trackUpdates(conn, cur, events)...)
+	}
+
+	if m.outputs == nil || slices.Contains(changed, "output") {
+		outputs, err := fetchOutputs(conn)
+		if err != nil {
+			if isConnectionError(err) {
+				return err
+			}
+			if m.config.Debug {
+				log.Printf("⚠️  Output update failed: %v", err)
+			}
+		} else {
+			if m.outputs != nil {
+				events = append(events, diffOutputs(m.outputs, outputs, Header{Current: cur})...)
+			}
+			m.outputs = outputs
+		}
+	}
+
+	if slices.Contains(changed, "message") {
+		messages, err := readMessages(conn, Header{Current: cur})
+		if err != nil && isConnectionError(err) {
+			return err
+		}
+		events = append(events, messages...)
+	}
+
+	if m.config.Debug && len(events) > 0 {
+		log.Printf("⏱️  %d event(s) %v after the change", len(events), cur.Latency.Round(time.Microsecond))
+	}
+	for _, ev := range events {
+		m.emit(ctx, ev)
+	}
+	m.last = cur
+
+	if m.config.ClearErrors && cur.Status["error"] != "" {
+		if _, err := conn.Command("clearerror"); err != nil {
+			return fmt.Errorf("failed to clear error: %w", err)
+		}
+		if m.config.Debug {
+			log.Printf("🧹 Cleared MPD error: %s", cur.Status["error"])
+		}
+	}
+
+	return nil
+}
+
+// trackUpdates times database update jobs among events and, once a job
+// finished, returns a digest of what it added to the library.
+func (m *Monitor) trackUpdates(conn *mpdproto.Conn, cur Snapshot, events []Event) []Event {
+	finished := false
+	for i, ev := range events {
+		switch e := ev.(type) {
+		case UpdateStarted:
+			m.updateStart = cur.Time
+		case UpdateFinished:
+			if !m.updateStart.IsZero() {
+				e.Duration = cur.Time.Sub(m.updateStart)
+				events[i] = e
+			}
+			m.updateStart = time.Time{}
+			finished = true
+		}
+	}
+
+	// Load the library once while no update is running, to compare later
+	if !finished && (m.library.loaded || cur.UpdateJob() != 0) {
+		return nil
+	}
+
+	albums, artists, err := m.library.refresh(conn)
+	if err != nil {
+		if m.config.Debug {
+			log.Printf("⚠️  Library digest unavailable: %v", err)
+		}
+		return nil
+	}
+	if len(albums) == 0 && len(artists) == 0 {
+		return nil
+	}
+	return []Event{LibraryDigest{Header: Header{Current: cur}, Albums: albums, Artists: artists}}
+}
+
+// snapshot fetches status, current song and replay gain mode in one round
+// trip. The batch doubles as the connection check, so no separate ping is
+// needed.
+func snapshot(conn *mpdproto.Conn) (Snapshot, error) {
+	resps, err := conn.CommandList(
+		mpdproto.NewCmd("status"),
+		mpdproto.NewCmd("currentsong"),
+		mpdproto.NewCmd("replay_gain_status"),
+	)
+	if err != nil && (len(resps) < 2 || isConnectionError(err)) {
+		return Snapshot{}, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	snap := Snapshot{Status: resps[0].Attrs(), Song: resps[1].Attrs(), Time: time.Now()}
+	if len(resps) > 2 {
+		// Servers refusing replay_gain_status leave it empty
+		snap.ReplayGain = resps[2].Attrs()["replay_gain_mode"]
+	}
+	return snap, nil
+}
+
+// reconnect re-establishes the connection, waiting between attempts as the
+// reconnect policy says. It gives up when the policy's attempts are used
+// up, when MPD rejects the password, or when ctx is cancelled.
+func (m *Monitor) reconnect(ctx context.Context, cause error) (*mpdproto.Conn, error) {
+	for {
+		delay, ok := m.backoff.next(cause)
+		if !ok {
+			state := m.backoff.current()
+			return nil, fmt.Errorf("failed to reconnect after %d attempts: %w", state.Attempt, cause)
+		}
+
+		state := m.backoff.current()
+		if m.config.Debug {
+			log.Printf("🔄 Reconnecting to MPD (%s): %v", state, cause)
+		}
+		m.emit(ctx, Reconnecting{Header: Header{Current: m.last}, State: state})
+
+		if !sleep(ctx, delay) {
+			return nil, ctx.Err()
+		}
+
+		conn, err := m.connect()
+		if err != nil {
+			if Classify(err) == ClassAuth {
+				return nil, err
+			}
+			if m.config.Debug {
+				log.Printf("🔄 Reconnect attempt %d failed: %v", state.Attempt, err)
+			}
+			cause = err
+			continue
+		}
+
+		if m.config.Debug {
+			log.Printf("✅ Reconnected to MPD on attempt %d", state.Attempt)
+		}
+		m.emit(ctx, Reconnected{Header: Header{Current: m.last}, Attempts: state.Attempt})
+		return conn, nil
+	}
+}
+
+// connect dials MPD, authenticates and switches to the configured
+// partition.
+func (m *Monitor) connect() (*mpdproto.Conn, error) {
+	network, addr := m.config.Endpoint()
+
+	conn, err := mpdproto.Dial(network, addr, m.config.Password, m.config.timeout())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MPD at %s: %w", addr, err)
+	}
+
+	if m.config.Partition != "" {
+		if _, err := conn.Command("partition", m.config.Partition); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select partition %q: %w", m.config.Partition, err)
+		}
+	}
+
+	if m.config.Channel != "" {
+		if _, err := conn.Command("subscribe", m.config.Channel); err != nil {
+			if isConnectionError(err) {
+				conn.Close()
+				return nil, err
+			}
+			// Monitoring works without remote commands
+			log.Printf("⚠️  Cannot subscribe to channel %q: %v", m.config.Channel, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// readMessages fetches the messages waiting on the subscribed channels.
+func readMessages(conn *mpdproto.Conn, h Header) ([]Event, error) {
+	resp, err := conn.Command("readmessages")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	var events []Event
+	for _, attrs := range resp.List("channel") {
+		events = append(events, MessageReceived{Header: h, Channel: attrs["channel"], Text: attrs["message"]})
+	}
+	return events, nil
+}
@@ -0,0 +1,252 @@
+package monitor_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/mpdtest"
+)
+
+func TestAlbumArtOnIdleConnection(t *testing.T) {
+	srv := newServer(t)
+	picture := bytes.Repeat([]byte{0x89}, 20000) // several chunks
+	srv.SetPicture("a.flac", picture, "image/png")
+
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	art, err := m.AlbumArt("a.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(art, picture) {
+		t.Errorf("got %d bytes of artwork, want %d", len(art), len(picture))
+	}
+	if !slices.Contains(srv.Commands(), "noidle") {
+		t.Error("the request did not interrupt idle")
+	}
+
+	// The connection went back to idle and still reports changes
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	waitFor(t, events, monitor.KindSongChanged)
+}
+
+func TestRequestWithoutConnection(t *testing.T) {
+	srv := newServer(t)
+	cfg := config(srv)
+	cfg.Timeout = 1
+	m := monitor.New(cfg)
+
+	// Run was never called, so nothing picks the request up
+	if _, err := m.AlbumArt("a.flac"); !errors.Is(err, monitor.ErrDisconnected) {
+		t.Errorf("AlbumArt error = %v, want ErrDisconnected", err)
+	}
+}
+
+func TestStatusInOneCommandList(t *testing.T) {
+	srv := newServer(t)
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.ResetCommands()
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	waitFor(t, events, monitor.KindSongChanged)
+
+	cmds := srv.Commands()
+	i := slices.Index(cmds, "command_list_ok_begin")
+	if i < 0 || len(cmds) < i+5 {
+		t.Fatalf("no command list in %q", cmds)
+	}
+	want := []string{"status", "currentsong", "replay_gain_status", "command_list_end"}
+	if got := cmds[i+1 : i+5]; !slices.Equal(got, want) {
+		t.Errorf("command list = %q, want %q", got, want)
+	}
+}
+
+func TestStatusWithoutReplayGain(t *testing.T) {
+	srv := newServer(t)
+	srv.Fail("replay_gain_status", "unknown command")
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	// Servers refusing replay_gain_status are still monitored
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	song := waitFor(t, events, monitor.KindSongChanged)
+	if mode := song.Snapshot().ReplayGain; mode != "" {
+		t.Errorf("ReplayGain = %q, want empty", mode)
+	}
+}
+
+func TestAlbumArtFallsBackToCoverFile(t *testing.T) {
+	srv := newServer(t)
+	cover := bytes.Repeat([]byte{0xff}, 10000)
+	srv.SetAlbumArt("a.flac", cover)
+
+	m := monitor.New(config(srv))
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.ResetCommands()
+	art, err := m.AlbumArt("a.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(art, cover) {
+		t.Errorf("got %d bytes of artwork, want %d", len(art), len(cover))
+	}
+
+	// Both lookups went out in one batch
+	cmds := srv.Commands()
+	i := slices.Index(cmds, "command_list_ok_begin")
+	if i < 0 || len(cmds) < i+3 || !strings.HasPrefix(cmds[i+1], "readpicture") || !strings.HasPrefix(cmds[i+2], "albumart") {
+		t.Errorf("readpicture and albumart not batched: %q", cmds)
+	}
+}
+
+func TestChannelMessages(t *testing.T) {
+	srv := newServer(t)
+	cfg := config(srv)
+	cfg.Channel = "mpdmon"
+	m := monitor.New(cfg)
+	events := m.Subscribe(monitor.KindMessageReceived)
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.SendMessage("other", "ignored")
+	srv.SendMessage("mpdmon", "mute 30m")
+	srv.SendMessage("mpdmon", "renotify")
+	for _, want := range []string{"mute 30m", "renotify"} {
+		e := waitFor(t, events, monitor.KindMessageReceived).(monitor.MessageReceived)
+		if e.Channel != "mpdmon" || e.Text != want {
+			t.Errorf("message %s: %q, want mpdmon: %q", e.Channel, e.Text, want)
+		}
+	}
+}
+
+// raise sets MPD's error, as a failing decoder or output would.
+func raise(srv *mpdtest.Server, msg string) {
+	srv.Update(func(status, _ mpd.Attrs) { status["error"] = msg }, "player")
+}
+
+func TestClearErrors(t *testing.T) {
+	srv := newServer(t)
+	cfg := config(srv)
+	cfg.ClearErrors = true
+	m := monitor.New(cfg)
+	events := m.Subscribe(monitor.KindErrorRaised)
+	start(t, m)
+	waitIdle(t, srv)
+
+	// The same failure twice is reported twice, as it was cleared in between
+	for range 2 {
+		raise(srv, "Failed to decode a.flac")
+		e := waitFor(t, events, monitor.KindErrorRaised).(monitor.ErrorRaised)
+		if e.Message != "Failed to decode a.flac" {
+			t.Errorf("Message = %q", e.Message)
+		}
+		waitCleared(t, srv)
+		srv.ResetCommands()
+	}
+}
+
+func TestErrorsNotCleared(t *testing.T) {
+	srv := newServer(t)
+	m := monitor.New(config(srv))
+	events := m.Subscribe(monitor.KindErrorRaised)
+	start(t, m)
+	waitIdle(t, srv)
+
+	raise(srv, "Failed to decode a.flac")
+	waitFor(t, events, monitor.KindErrorRaised)
+	raise(srv, "Failed to decode a.flac")
+	select {
+	case ev := <-events:
+		t.Errorf("the standing error was reported again: %v", ev)
+	case <-time.After(300 * time.Millisecond):
+	}
+	if slices.Contains(srv.Commands(), "clearerror") {
+		t.Error("clearerror sent without ClearErrors")
+	}
+}
+
+// waitCleared waits until the monitor sent clearerror and went idle again
+// after seeing the status it led to.
+func waitCleared(t *testing.T, srv *mpdtest.Server) {
+	t.Helper()
+	deadline := time.Now().Add(eventTimeout)
+	for time.Now().Before(deadline) {
+		cmds := srv.Commands()
+		if i := slices.Index(cmds, "clearerror"); i >= 0 {
+			after := cmds[i+1:]
+			if slices.Contains(after, "status") && strings.HasPrefix(after[len(after)-1], "idle") {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("the error was not cleared")
+}
+
+func TestReconnectWhenStatusHangs(t *testing.T) {
+	srv := newServer(t)
+	cfg := config(srv)
+	cfg.Timeout = 1
+	m := monitor.New(cfg)
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Stall("status", time.Minute)
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	reconnecting := waitFor(t, events, monitor.KindReconnecting).(monitor.Reconnecting)
+	if !errors.Is(reconnecting.State.LastError, monitor.ErrTimeout) {
+		t.Errorf("reconnecting after %v, want ErrTimeout", reconnecting.State.LastError)
+	}
+
+	srv.Stall("status", 0)
+	waitFor(t, events, monitor.KindReconnected)
+	song := waitFor(t, events, monitor.KindSongChanged).(monitor.SongChanged)
+	if song.New["file"] != "a.flac" {
+		t.Errorf("New file = %q, want a.flac", song.New["file"])
+	}
+}
+
+func TestAlbumArtTimeout(t *testing.T) {
+	srv := newServer(t)
+	srv.SetPicture("a.flac", make([]byte, 100), "image/png")
+	cfg := config(srv)
+	cfg.Timeout = 1
+	m := monitor.New(cfg)
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Stall("readpicture", time.Minute)
+	begin := time.Now()
+	if _, err := m.AlbumArt("a.flac"); !errors.Is(err, monitor.ErrTimeout) {
+		t.Errorf("AlbumArt error = %v, want ErrTimeout", err)
+	}
+	if took := time.Since(begin); took > 3*time.Second {
+		t.Errorf("AlbumArt gave up after %v, want about 1s", took)
+	}
+
+	// The connection is out of step after a timeout and is replaced
+	srv.Stall("readpicture", 0)
+	waitFor(t, events, monitor.KindReconnected)
+	if _, err := m.AlbumArt("a.flac"); err != nil {
+		t.Errorf("AlbumArt after reconnecting: %v", err)
+	}
+}
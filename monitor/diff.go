@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"time"
+)
+
+// seekTolerance is how far the reported position may drift from the
+// expected one before it counts as a seek.
+const seekTolerance = 2 * time.Second
+
+// diff returns the events describing the change from old to cur. A zero
+// old snapshot means cur is the first one; only the current song is
+// reported then.
+func diff(old, cur Snapshot) []Event {
+	h := Header{Current: cur}
+
+	if old.Status == nil {
+		if cur.File() == "" {
+			return nil
+		}
+		return []Event{SongChanged{Header: h, New: cur.Song}}
+	}
+
+	var events []Event
+
+	if old.State() != cur.State() {
+		events = append(events, StateChanged{Header: h, Old: old.State(), New: cur.State()})
+	}
+
+	sameSong := old.File() == cur.File() && old.Status["songid"] == cur.Status["songid"]
+	if !sameSong && cur.File() != "" {
+		events = append(events, SongChanged{Header: h, Old: old.Song, New: cur.Song})
+	}
+
+	// Streams keep their URI while the station announces each track in
+	// Title; a title going blank between tracks is not worth reporting
+	if sameSong && cur.Stream() && cur.Song["Title"] != "" && old.Song["Title"] != cur.Song["Title"] {
+		events = append(events, StreamTitleChanged{Header: h, Old: old.Song["Title"], New: cur.Song["Title"]})
+	}
+
+	if sameSong && cur.File() != "" {
+		events = append(events, seek(old, cur, h)...)
+	}
+
+	if old.Volume() != cur.Volume() {
+		events = append(events, VolumeChanged{Header: h, Old: old.Volume(), New: cur.Volume()})
+	}
+
+	if old.Options() != cur.Options() {
+		events = append(events, OptionsChanged{Header: h, Old: old.Options(), New: cur.Options()})
+	}
+
+	if old.PlaylistVersion() != cur.PlaylistVersion() {
+		events = append(events, QueueChanged{
+			Header:     h,
+			OldVersion: old.PlaylistVersion(),
+			NewVersion: cur.PlaylistVersion(),
+			OldLength:  old.PlaylistLength(),
+			NewLength:  cur.PlaylistLength(),
+		})
+	}
+
+	switch oldJob, curJob := old.UpdateJob(), cur.UpdateJob(); {
+	case oldJob == curJob:
+	case oldJob == 0:
+		events = append(events, UpdateStarted{Header: h, Job: curJob})
+	case curJob == 0:
+		events = append(events, UpdateFinished{Header: h, Job: oldJob})
+	default:
+		// One job ended and the next began between two checks
+		events = append(events, UpdateFinished{Header: h, Job: oldJob}, UpdateStarted{Header: h, Job: curJob})
+	}
+
+	if msg := cur.Status["error"]; msg != "" && msg != old.Status["error"] {
+		events = append(events, ErrorRaised{Header: h, Old: old.Status["error"], Message: msg})
+	}
+
+	return events
+}
+
+// seek compares the position within a song that is current in both old
+// and cur with where the wall clock says it should be. A jump back to the
+// start counts as a restart, as does playing the song again after a stop.
+func seek(old, cur Snapshot, h Header) []Event {
+	if cur.State() == "stop" {
+		return nil
+	}
+	if old.State() == "stop" {
+		if cur.Elapsed() <= seekTolerance {
+			return []Event{TrackRestarted{Header: h}}
+		}
+		return nil
+	}
+
+	expected := old.Elapsed()
+	if old.State() == "play" {
+		expected += cur.Time.Sub(old.Time)
+	}
+	if d := cur.Duration(); d > 0 && expected > d {
+		// The song ended since; in single mode it started over
+		expected = d
+	}
+
+	drift := cur.Elapsed() - expected
+	switch {
+	case drift < -seekTolerance && cur.Elapsed() <= seekTolerance:
+		return []Event{TrackRestarted{Header: h, From: expected}}
+	case drift > seekTolerance || drift < -seekTolerance:
+		return []Event{Seeked{Header: h, From: expected, To: cur.Elapsed()}}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// t0 is when the older snapshot of each pair is taken.
+var t0 = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// snap returns a snapshot taken at at of a stopped player with a.flac
+// current, with the given status and song attributes set on top.
+func snap(at time.Time, status mpd.Attrs, song mpd.Attrs) Snapshot {
+	s := Snapshot{
+		Status: mpd.Attrs{"state": "stop", "songid": "1", "volume": "50", "playlist": "3", "playlistlength": "2"},
+		Song:   mpd.Attrs{"file": "a.flac", "Id": "1"},
+		Time:   at,
+	}
+	for k, v := range status {
+		s.Status[k] = v
+	}
+	for k, v := range song {
+		s.Song[k] = v
+	}
+	return s
+}
+
+// kinds returns the kinds of events.
+func kinds(events []Event) []Kind {
+	var out []Kind
+	for _, ev := range events {
+		out = append(out, ev.Kind())
+	}
+	return out
+}
+
+func TestDiffFirstSnapshot(t *testing.T) {
+	cur := snap(t0, mpd.Attrs{"state": "play", "volume": "80", "error": "oops"}, nil)
+	events := diff(Snapshot{}, cur)
+	if got := kinds(events); !reflect.DeepEqual(got, []Kind{KindSongChanged}) {
+		t.Fatalf("events = %v, want only song_changed", got)
+	}
+	if song := events[0].(SongChanged); song.Old != nil || song.New["file"] != "a.flac" {
+		t.Errorf("SongChanged = %v -> %v, want nil -> a.flac", song.Old, song.New)
+	}
+
+	empty := Snapshot{Status: mpd.Attrs{"state": "stop"}, Song: mpd.Attrs{}, Time: t0}
+	if events := diff(Snapshot{}, empty); len(events) != 0 {
+		t.Errorf("empty player: events = %v, want none", kinds(events))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, cur Snapshot
+		want     []Event
+	}{
+		{
+			name: "nothing changed",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0.Add(time.Second), nil, nil),
+		},
+		{
+			name: "state",
+			old:  snap(t0, mpd.Attrs{"state": "play"}, nil),
+			cur:  snap(t0, mpd.Attrs{"state": "pause"}, nil),
+			want: []Event{StateChanged{Old: "play", New: "pause"}},
+		},
+		{
+			name: "same file, new queue entry",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"songid": "7"}, mpd.Attrs{"Id": "7"}),
+			want: []Event{SongChanged{Old: mpd.Attrs{"file": "a.flac", "Id": "1"}, New: mpd.Attrs{"file": "a.flac", "Id": "7"}}},
+		},
+		{
+			name: "volume",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"volume": "65"}, nil),
+			want: []Event{VolumeChanged{Old: 50, New: 65}},
+		},
+		{
+			name: "mixer gone",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"volume": ""}, nil),
+			want: []Event{VolumeChanged{Old: 50, New: -1}},
+		},
+		{
+			name: "options",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"repeat": "1", "single": "oneshot"}, nil),
+			want: []Event{OptionsChanged{Old: Options{Single: "0", Consume: "0"}, New: Options{Repeat: true, Single: "oneshot", Consume: "0"}}},
+		},
+		{
+			name: "queue version",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"playlist": "4", "playlistlength": "3"}, nil),
+			want: []Event{QueueChanged{OldVersion: 3, NewVersion: 4, OldLength: 2, NewLength: 3}},
+		},
+		{
+			name: "update started",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"updating_db": "5"}, nil),
+			want: []Event{UpdateStarted{Job: 5}},
+		},
+		{
+			name: "update finished",
+			old:  snap(t0, mpd.Attrs{"updating_db": "5"}, nil),
+			cur:  snap(t0, nil, nil),
+			want: []Event{UpdateFinished{Job: 5}},
+		},
+		{
+			name: "one update ended and the next began",
+			old:  snap(t0, mpd.Attrs{"updating_db": "5"}, nil),
+			cur:  snap(t0, mpd.Attrs{"updating_db": "6"}, nil),
+			want: []Event{UpdateFinished{Job: 5}, UpdateStarted{Job: 6}},
+		},
+		{
+			name: "error raised",
+			old:  snap(t0, nil, nil),
+			cur:  snap(t0, mpd.Attrs{"error": "Failed to open output"}, nil),
+			want: []Event{ErrorRaised{Message: "Failed to open output"}},
+		},
+		{
+			name: "error replaced",
+			old:  snap(t0, mpd.Attrs{"error": "Failed to open output"}, nil),
+			cur:  snap(t0, mpd.Attrs{"error": "Failed to decode a.flac"}, nil),
+			want: []Event{ErrorRaised{Old: "Failed to open output", Message: "Failed to decode a.flac"}},
+		},
+		{
+			name: "error still standing",
+			old:  snap(t0, mpd.Attrs{"error": "Failed to open output"}, nil),
+			cur:  snap(t0, mpd.Attrs{"error": "Failed to open output"}, nil),
+		},
+		{
+			name: "error cleared",
+			old:  snap(t0, mpd.Attrs{"error": "Failed to open output"}, nil),
+			cur:  snap(t0, nil, nil),
+		},
+	}
+	for _, tt := range tests {
+		got := diff(tt.old, tt.cur)
+		if !sameEvents(got, tt.want, tt.cur) {
+			t.Errorf("%s: events = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sameEvents reports whether got equals want once cur, the snapshot every
+// event carries, is filled into want.
+func sameEvents(got, want []Event, cur Snapshot) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i, ev := range want {
+		v := reflect.New(reflect.TypeOf(ev)).Elem()
+		v.Set(reflect.ValueOf(ev))
+		v.FieldByName("Header").Set(reflect.ValueOf(Header{Current: cur}))
+		if !reflect.DeepEqual(got[i], v.Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiffSeek(t *testing.T) {
+	sec := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+	playingAt := func(elapsed string) mpd.Attrs {
+		return mpd.Attrs{"state": "play", "elapsed": elapsed, "duration": "200"}
+	}
+
+	tests := []struct {
+		name     string
+		old, cur Snapshot
+		want     []Event
+	}{
+		{
+			name: "forward",
+			old:  snap(t0, playingAt("10"), nil),
+			cur:  snap(t0.Add(time.Second), playingAt("60"), nil),
+			want: []Event{Seeked{From: sec(11), To: sec(60)}},
+		},
+		{
+			name: "backward",
+			old:  snap(t0, playingAt("60"), nil),
+			cur:  snap(t0.Add(time.Second), playingAt("30"), nil),
+			want: []Event{Seeked{From: sec(61), To: sec(30)}},
+		},
+		{
+			name: "drift within tolerance",
+			old:  snap(t0, playingAt("10"), nil),
+			cur:  snap(t0.Add(time.Second), playingAt("12.5"), nil),
+		},
+		{
+			name: "back to the start",
+			old:  snap(t0, playingAt("100"), nil),
+			cur:  snap(t0.Add(time.Second), playingAt("0.5"), nil),
+			want: []Event{TrackRestarted{From: sec(101)}},
+		},
+		{
+			// Without the cap at the duration From would be 203s
+			name: "ended and started over in single mode",
+			old:  snap(t0, playingAt("198"), nil),
+			cur:  snap(t0.Add(5*time.Second), playingAt("1"), nil),
+			want: []Event{TrackRestarted{From: sec(200)}},
+		},
+		{
+			name: "played again after a stop",
+			old:  snap(t0, mpd.Attrs{"state": "stop"}, nil),
+			cur:  snap(t0.Add(time.Minute), playingAt("0.5"), nil),
+			want: []Event{StateChanged{Old: "stop", New: "play"}, TrackRestarted{}},
+		},
+		{
+			name: "resumed mid-song after a stop",
+			old:  snap(t0, mpd.Attrs{"state": "stop"}, nil),
+			cur:  snap(t0.Add(time.Minute), playingAt("30"), nil),
+			want: []Event{StateChanged{Old: "stop", New: "play"}},
+		},
+		{
+			name: "paused, then resumed",
+			old:  snap(t0, mpd.Attrs{"state": "pause", "elapsed": "50", "duration": "200"}, nil),
+			cur:  snap(t0.Add(10*time.Minute), playingAt("50.2"), nil),
+			want: []Event{StateChanged{Old: "pause", New: "play"}},
+		},
+		{
+			name: "seeked while paused",
+			old:  snap(t0, mpd.Attrs{"state": "pause", "elapsed": "50", "duration": "200"}, nil),
+			cur:  snap(t0.Add(10*time.Minute), mpd.Attrs{"state": "pause", "elapsed": "120", "duration": "200"}, nil),
+			want: []Event{Seeked{From: sec(50), To: sec(120)}},
+		},
+		{
+			name: "stopped",
+			old:  snap(t0, playingAt("50"), nil),
+			cur:  snap(t0.Add(time.Second), mpd.Attrs{"state": "stop"}, nil),
+			want: []Event{StateChanged{Old: "play", New: "stop"}},
+		},
+	}
+	for _, tt := range tests {
+		got := diff(tt.old, tt.cur)
+		if !sameEvents(got, tt.want, tt.cur) {
+			t.Errorf("%s: events = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitStreamTitle(t *testing.T) {
+	tests := []struct {
+		in, artist, title string
+	}{
+		{"Nina Simone - Feeling Good", "Nina Simone", "Feeling Good"},
+		{"  Nina Simone  -  Feeling Good ", "Nina Simone", "Feeling Good"},
+		{"Jay-Z - 99 Problems", "Jay-Z", "99 Problems"},
+		{"A - B - C", "A", "B - C"},
+		{"Station jingle", "", "Station jingle"},
+		{" - Feeling Good", "", "- Feeling Good"},
+		{"Nina Simone - ", "", "Nina Simone -"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		artist, title := SplitStreamTitle(tt.in)
+		if artist != tt.artist || title != tt.title {
+			t.Errorf("SplitStreamTitle(%q) = %q, %q; want %q, %q", tt.in, artist, title, tt.artist, tt.title)
+		}
+	}
+}
+
+func TestDiffStreamTitle(t *testing.T) {
+	radio := mpd.Attrs{"file": "http://radio.example/stream", "Name": "Jazz FM"}
+	withTitle := func(title string) mpd.Attrs {
+		song := mpd.Attrs{"Title": title}
+		for k, v := range radio {
+			song[k] = v
+		}
+		return song
+	}
+
+	tests := []struct {
+		name     string
+		old, cur Snapshot
+		want     []Event
+	}{
+		{
+			name: "new title",
+			old:  snap(t0, nil, withTitle("Nina Simone - Feeling Good")),
+			cur:  snap(t0, nil, withTitle("Miles Davis - So What")),
+			want: []Event{StreamTitleChanged{Old: "Nina Simone - Feeling Good", New: "Miles Davis - So What"}},
+		},
+		{
+			name: "first title",
+			old:  snap(t0, nil, radio),
+			cur:  snap(t0, nil, withTitle("Station jingle")),
+			want: []Event{StreamTitleChanged{New: "Station jingle"}},
+		},
+		{
+			name: "blank title between tracks",
+			old:  snap(t0, nil, withTitle("Nina Simone - Feeling Good")),
+			cur:  snap(t0, nil, withTitle("")),
+		},
+		{
+			name: "same title",
+			old:  snap(t0, nil, withTitle("Nina Simone - Feeling Good")),
+			cur:  snap(t0, nil, withTitle("Nina Simone - Feeling Good")),
+		},
+		{
+			name: "tags of a file",
+			old:  snap(t0, nil, mpd.Attrs{"Title": "Old tag"}),
+			cur:  snap(t0, nil, mpd.Attrs{"Title": "New tag"}),
+		},
+	}
+	for _, tt := range tests {
+		got := diff(tt.old, tt.cur)
+		if !sameEvents(got, tt.want, tt.cur) {
+			t.Errorf("%s: events = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	ev := StreamTitleChanged{New: "Nina Simone - Feeling Good"}
+	if artist, title := ev.Split(); artist != "Nina Simone" || title != "Feeling Good" {
+		t.Errorf("Split() = %q, %q", artist, title)
+	}
+}
+
+func TestVolumeMuted(t *testing.T) {
+	tests := []struct {
+		old, new       int
+		muted, unmuted bool
+	}{
+		{50, 60, false, false},
+		{50, 0, true, false},
+		{50, -1, true, false},
+		{0, 50, false, true},
+		{-1, 50, false, true},
+		{0, -1, false, false},
+	}
+	for _, tt := range tests {
+		ev := VolumeChanged{Old: tt.old, New: tt.new}
+		if ev.Muted() != tt.muted || ev.Unmuted() != tt.unmuted {
+			t.Errorf("%d -> %d: Muted %t, Unmuted %t; want %t, %t", tt.old, tt.new, ev.Muted(), ev.Unmuted(), tt.muted, tt.unmuted)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"syscall"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// ErrDisconnected is returned when there is no usable connection to MPD,
+// e.g. when a request is made while Run is reconnecting.
+var ErrDisconnected = errors.New("connection to MPD lost")
+
+// ErrorClass tells the monitor how to react to an error.
+type ErrorClass int
+
+const (
+	// ClassNone is the class of a nil error.
+	ClassNone ErrorClass = iota
+	// ClassTransport means the connection is broken or stuck. The monitor
+	// reconnects.
+	ClassTransport
+	// ClassAuth means MPD refused a command for lack of a (correct)
+	// password. Reconnecting with the same password cannot help.
+	ClassAuth
+	// ClassProtocol means MPD answered a command with an error, e.g. no
+	// artwork for a file. The connection itself is fine.
+	ClassProtocol
+	// ClassOther is anything not recognised above.
+	ClassOther
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassNone:
+		return "none"
+	case ClassTransport:
+		return "transport"
+	case ClassAuth:
+		return "auth"
+	case ClassProtocol:
+		return "protocol"
+	default:
+		return "other"
+	}
+}
+
+// Classify sorts err into an ErrorClass. It is the one place deciding
+// whether an error warrants a reconnect.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+
+	if code, ok := AckCode(err); ok {
+		switch code {
+		case mpd.ErrorPassword, mpd.ErrorPermission:
+			return ClassAuth
+		default:
+			return ClassProtocol
+		}
+	}
+
+	var netErr net.Error
+	var protoErr textproto.ProtocolError
+	switch {
+	case errors.Is(err, ErrTimeout),
+		errors.Is(err, ErrDisconnected),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ENOENT), // socket file gone after an MPD restart
+		errors.As(err, &netErr),
+		errors.As(err, &protoErr): // garbled reply, the stream is out of sync
+		return ClassTransport
+	}
+
+	return ClassOther
+}
+
+// AckCode returns the code of an MPD ACK error wrapped in err.
+func AckCode(err error) (mpd.ErrorCode, bool) {
+	var ack mpd.Error
+	if errors.As(err, &ack) {
+		return ack.Code, true
+	}
+	var ackPtr *mpd.Error
+	if errors.As(err, &ackPtr) && ackPtr != nil {
+		return ackPtr.Code, true
+	}
+	return 0, false
+}
+
+func isConnectionError(err error) bool {
+	return Classify(err) == ClassTransport
+}
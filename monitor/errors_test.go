@@ -0,0 +1,67 @@
+package monitor_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"syscall"
+	"testing"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestClassify(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("failed to get status: %w", err) }
+
+	tests := []struct {
+		name string
+		err  error
+		want monitor.ErrorClass
+	}{
+		{"nil", nil, monitor.ClassNone},
+		{"timeout", monitor.ErrTimeout, monitor.ClassTransport},
+		{"wrapped timeout", wrap(monitor.ErrTimeout), monitor.ClassTransport},
+		{"disconnected", monitor.ErrDisconnected, monitor.ClassTransport},
+		{"EOF", io.EOF, monitor.ClassTransport},
+		{"unexpected EOF", wrap(io.ErrUnexpectedEOF), monitor.ClassTransport},
+		{"closed connection", wrap(net.ErrClosed), monitor.ClassTransport},
+		{"connection reset", wrap(syscall.ECONNRESET), monitor.ClassTransport},
+		{"connection refused", wrap(syscall.ECONNREFUSED), monitor.ClassTransport},
+		{"broken pipe", wrap(syscall.EPIPE), monitor.ClassTransport},
+		{"socket gone", wrap(syscall.ENOENT), monitor.ClassTransport},
+		{"net.Error", wrap(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("i/o timeout")}), monitor.ClassTransport},
+		{"garbled reply", wrap(textproto.ProtocolError("short response")), monitor.ClassTransport},
+		{"wrong password", wrap(mpd.Error{Code: mpd.ErrorPassword}), monitor.ClassAuth},
+		{"wrong password by pointer", wrap(&mpd.Error{Code: mpd.ErrorPassword}), monitor.ClassAuth},
+		{"permission", wrap(mpd.Error{Code: mpd.ErrorPermission}), monitor.ClassAuth},
+		{"permission by pointer", wrap(&mpd.Error{Code: mpd.ErrorPermission}), monitor.ClassAuth},
+		{"no such file", wrap(mpd.Error{Code: mpd.ErrorNoExist}), monitor.ClassProtocol},
+		{"no such file by pointer", wrap(&mpd.Error{Code: mpd.ErrorNoExist}), monitor.ClassProtocol},
+		{"unknown command", mpd.Error{Code: mpd.ErrorUnknown}, monitor.ClassProtocol},
+		{"other", errors.New("something else"), monitor.ClassOther},
+	}
+	for _, tt := range tests {
+		if got := monitor.Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify(%v) = %s, want %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAckCode(t *testing.T) {
+	for _, err := range []error{mpd.Error{Code: mpd.ErrorNoExist}, &mpd.Error{Code: mpd.ErrorNoExist}} {
+		if code, ok := monitor.AckCode(fmt.Errorf("failed: %w", err)); !ok || code != mpd.ErrorNoExist {
+			t.Errorf("AckCode(%T) = %d, %t, want %d, true", err, code, ok, mpd.ErrorNoExist)
+		}
+	}
+	if _, ok := monitor.AckCode(io.EOF); ok {
+		t.Error("AckCode found an ACK in io.EOF")
+	}
+	var nilAck *mpd.Error
+	if _, ok := monitor.AckCode(nilAck); ok {
+		t.Error("AckCode found an ACK in a nil *mpd.Error")
+	}
+}
@@ -0,0 +1,359 @@
+package monitor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// Kind is the stable name of an event type, used to subscribe to a subset
+// of events.
+type Kind string
+
+const (
+	KindSongChanged        Kind = "song_changed"
+	KindStateChanged       Kind = "state_changed"
+	KindVolumeChanged      Kind = "volume_changed"
+	KindOptionsChanged     Kind = "options_changed"
+	KindQueueChanged       Kind = "queue_changed"
+	KindTracksAdded        Kind = "tracks_added"
+	KindTracksRemoved      Kind = "tracks_removed"
+	KindTracksMoved        Kind = "tracks_moved"
+	KindQueueCleared       Kind = "queue_cleared"
+	KindSeeked             Kind = "seeked"
+	KindTrackRestarted     Kind = "track_restarted"
+	KindUpdateStarted      Kind = "update_started"
+	KindUpdateFinished     Kind = "update_finished"
+	KindLibraryDigest      Kind = "library_digest"
+	KindOutputChanged      Kind = "output_changed"
+	KindMessageReceived    Kind = "message_received"
+	KindStreamTitleChanged Kind = "stream_title_changed"
+	KindUpNext             Kind = "up_next"
+	KindErrorRaised        Kind = "error_raised"
+	KindReconnecting       Kind = "reconnecting"
+	KindReconnected        Kind = "reconnected"
+)
+
+// Event is implemented by every value delivered by a Monitor. Use a type
+// switch to get at the event-specific fields.
+type Event interface {
+	Kind() Kind
+	// Snapshot returns the player status taken after the change.
+	Snapshot() Snapshot
+}
+
+// Snapshot is the result of one status + currentsong query.
+type Snapshot struct {
+	Status mpd.Attrs
+	Song   mpd.Attrs
+	Time   time.Time // when the snapshot was taken
+
+	// ReplayGain is the replay gain mode from replay_gain_status.
+	ReplayGain string
+
+	// Server and Partition are the Config.Name and Config.Partition of the
+	// monitor that took the snapshot.
+	Server    string
+	Partition string
+
+	// Latency is the time from MPD reporting the change to the snapshot
+	// arriving, i.e. what the monitor adds on top of MPD itself.
+	Latency time.Duration
+}
+
+// Source identifies the player the snapshot belongs to, as "server",
+// "server/partition" or "/partition"; it is empty for a single server's
+// default partition.
+func (s Snapshot) Source() string {
+	if s.Partition == "" {
+		return s.Server
+	}
+	return s.Server + "/" + s.Partition
+}
+
+// State returns the player state: "play", "pause" or "stop".
+func (s Snapshot) State() string {
+	return s.Status["state"]
+}
+
+// File returns the URI of the current song.
+func (s Snapshot) File() string {
+	return s.Song["file"]
+}
+
+// Playing reports whether a song is currently playing.
+func (s Snapshot) Playing() bool {
+	return s.State() == "play" && s.File() != ""
+}
+
+// Stream reports whether the current song is a remote stream rather than a
+// file from the database.
+func (s Snapshot) Stream() bool {
+	return strings.Contains(s.File(), "://")
+}
+
+// Elapsed returns the playback position within the current song.
+func (s Snapshot) Elapsed() time.Duration {
+	sec, err := strconv.ParseFloat(s.Status["elapsed"], 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+// Duration returns the length of the current song, or 0 if it is unknown,
+// e.g. for streams.
+func (s Snapshot) Duration() time.Duration {
+	sec, err := strconv.ParseFloat(s.Status["duration"], 64)
+	if err != nil {
+		sec, err = strconv.ParseFloat(s.Song["duration"], 64)
+	}
+	if err != nil {
+		return 0
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+// Volume returns the mixer volume, or -1 if MPD has no mixer.
+func (s Snapshot) Volume() int {
+	return atoi(s.Status["volume"], -1)
+}
+
+// PlaylistVersion returns the queue version, which MPD bumps on every change.
+func (s Snapshot) PlaylistVersion() int {
+	return atoi(s.Status["playlist"], 0)
+}
+
+// UpdateJob returns the id of the running database update, or 0 if there
+// is none.
+func (s Snapshot) UpdateJob() int {
+	return atoi(s.Status["updating_db"], 0)
+}
+
+// PlaylistLength returns the number of songs in the queue.
+func (s Snapshot) PlaylistLength() int {
+	return atoi(s.Status["playlistlength"], 0)
+}
+
+// Header is embedded in every event.
+type Header struct {
+	Current Snapshot
+}
+
+// Snapshot implements Event.
+func (h Header) Snapshot() Snapshot {
+	return h.Current
+}
+
+// SongChanged is sent when a different song becomes the current one.
+type SongChanged struct {
+	Header
+	Old, New mpd.Attrs
+}
+
+// StateChanged is sent when the player switches between play, pause and stop.
+type StateChanged struct {
+	Header
+	Old, New string
+}
+
+// VolumeChanged is sent when the mixer volume changes. A volume of 0 or
+// -1 (no mixer, or the output is disabled) counts as muted.
+type VolumeChanged struct {
+	Header
+	Old, New int
+}
+
+// Muted reports whether the change silenced the player.
+func (e VolumeChanged) Muted() bool {
+	return e.New <= 0 && e.Old > 0
+}
+
+// Unmuted reports whether the change made a silenced player audible again.
+func (e VolumeChanged) Unmuted() bool {
+	return e.Old <= 0 && e.New > 0
+}
+
+// OptionsChanged is sent when repeat, random, single, consume, crossfade
+// or the replay gain mode change.
+type OptionsChanged struct {
+	Header
+	Old, New Options
+}
+
+// QueueChanged is sent when the queue version changes.
+type QueueChanged struct {
+	Header
+	OldVersion, NewVersion int
+	OldLength, NewLength   int
+}
+
+// TracksAdded is sent when entries were added to the queue, in queue order.
+type TracksAdded struct {
+	Header
+	Tracks []mpd.Attrs
+}
+
+// TracksRemoved is sent when entries were removed from the queue. Tracks
+// hold the entries as they were before the removal.
+type TracksRemoved struct {
+	Header
+	Tracks []mpd.Attrs
+}
+
+// TracksMoved is sent when entries changed their place in the queue.
+type TracksMoved struct {
+	Header
+	Moves []Move
+}
+
+// QueueCleared is sent instead of TracksRemoved when every entry was
+// removed. Tracks hold the entries of the old queue.
+type QueueCleared struct {
+	Header
+	Tracks []mpd.Attrs
+}
+
+// UpdateStarted is sent when MPD starts a database update job.
+type UpdateStarted struct {
+	Header
+	Job int
+}
+
+// UpdateFinished is sent when a database update job is over. Duration is
+// measured from when the monitor saw the job start, and is zero if it did
+// not.
+type UpdateFinished struct {
+	Header
+	Job      int
+	Duration time.Duration
+}
+
+// LibraryDigest is sent after a database update that added albums or
+// artists, compared with the library as it was before the update.
+type LibraryDigest struct {
+	Header
+	Albums  []Album
+	Artists []string
+}
+
+// OutputChanged is sent when an audio output is enabled or disabled.
+// Output holds its new state.
+type OutputChanged struct {
+	Header
+	Output Output
+}
+
+// MessageReceived is sent for each message another MPD client sent to
+// the subscribed channel with sendmessage.
+type MessageReceived struct {
+	Header
+	Channel, Text string
+}
+
+// Seeked is sent when the position within the current song jumps. From is
+// where playback would have been without the seek, judged by the wall
+// clock since the previous snapshot.
+type Seeked struct {
+	Header
+	From, To time.Duration
+}
+
+// TrackRestarted is sent when the current song starts over, e.g. when it
+// repeats in single mode, is seeked back to its start or is played again
+// after a stop. From is where playback was, and zero after a stop.
+type TrackRestarted struct {
+	Header
+	From time.Duration
+}
+
+// StreamTitleChanged is sent when a stream, e.g. internet radio, updates
+// its title from ICY metadata while the current song stays the same.
+type StreamTitleChanged struct {
+	Header
+	Old, New string
+}
+
+// Station returns the stream's name as announced by the station, if any.
+func (e StreamTitleChanged) Station() string {
+	return e.Current.Song["Name"]
+}
+
+// Split splits the new title into artist and title when it has the usual
+// "Artist - Title" form; otherwise artist is empty and title is New.
+func (e StreamTitleChanged) Split() (artist, title string) {
+	return SplitStreamTitle(e.New)
+}
+
+// SplitStreamTitle splits an ICY stream title of the form "Artist - Title".
+// Titles without the separator are returned whole, with an empty artist.
+func SplitStreamTitle(s string) (artist, title string) {
+	artist, title, ok := strings.Cut(s, " - ")
+	artist, title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	if !ok || artist == "" || title == "" {
+		return "", strings.TrimSpace(s)
+	}
+	return artist, title
+}
+
+// UpNext is sent shortly before the current song ends, as set by
+// Config.UpNext. Next is the queue entry that plays next and Remaining the
+// time until it starts.
+type UpNext struct {
+	Header
+	Next      mpd.Attrs
+	Remaining time.Duration
+}
+
+// ErrorRaised is sent when MPD reports a player error or a status check
+// fails. Message holds MPD's error text; Err is set for failed checks.
+type ErrorRaised struct {
+	Header
+	Old, Message string
+	Err          error
+}
+
+// Reconnecting is sent before each reconnect attempt. Current is the last
+// snapshot taken before the connection was lost.
+type Reconnecting struct {
+	Header
+	State BackoffState
+}
+
+// Reconnected is sent once a reconnect attempt succeeded.
+type Reconnected struct {
+	Header
+	Attempts int
+}
+
+func (SongChanged) Kind() Kind        { return KindSongChanged }
+func (StateChanged) Kind() Kind       { return KindStateChanged }
+func (VolumeChanged) Kind() Kind      { return KindVolumeChanged }
+func (OptionsChanged) Kind() Kind     { return KindOptionsChanged }
+func (QueueChanged) Kind() Kind       { return KindQueueChanged }
+func (TracksAdded) Kind() Kind        { return KindTracksAdded }
+func (TracksRemoved) Kind() Kind      { return KindTracksRemoved }
+func (TracksMoved) Kind() Kind        { return KindTracksMoved }
+func (QueueCleared) Kind() Kind       { return KindQueueCleared }
+func (Seeked) Kind() Kind             { return KindSeeked }
+func (TrackRestarted) Kind() Kind     { return KindTrackRestarted }
+func (UpdateStarted) Kind() Kind      { return KindUpdateStarted }
+func (UpdateFinished) Kind() Kind     { return KindUpdateFinished }
+func (LibraryDigest) Kind() Kind      { return KindLibraryDigest }
+func (OutputChanged) Kind() Kind      { return KindOutputChanged }
+func (MessageReceived) Kind() Kind    { return KindMessageReceived }
+func (StreamTitleChanged) Kind() Kind { return KindStreamTitleChanged }
+func (UpNext) Kind() Kind             { return KindUpNext }
+func (ErrorRaised) Kind() Kind        { return KindErrorRaised }
+func (Reconnecting) Kind() Kind       { return KindReconnecting }
+func (Reconnected) Kind() Kind        { return KindReconnected }
+
+func atoi(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
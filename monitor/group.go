@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Group runs one Monitor per MPD server or partition and merges their
+// events into a single stream. Events tell their origin apart by
+// Snapshot().Source().
+type Group struct {
+	monitors []*Monitor
+	byName   map[string]*Monitor // first monitor of each server
+
+	once   sync.Once
+	events <-chan Event
+}
+
+// NewGroup returns a Group with a Monitor for each of configs. Configs of
+// the same server differ in Partition; each name and partition pair must
+// be unique.
+func NewGroup(configs ...Config) (*Group, error) {
+	g := &Group{byName: make(map[string]*Monitor, len(configs))}
+	seen := make(map[[2]string]bool, len(configs))
+	for _, config := range configs {
+		key := [2]string{config.Name, config.Partition}
+		if seen[key] {
+			if config.Partition != "" {
+				return nil, fmt.Errorf("duplicate partition %q for MPD server %q", config.Partition, config.Name)
+			}
+			return nil, fmt.Errorf("duplicate MPD server name %q", config.Name)
+		}
+		seen[key] = true
+
+		m := New(config)
+		g.monitors = append(g.monitors, m)
+		if g.byName[config.Name] == nil {
+			g.byName[config.Name] = m
+		}
+	}
+	return g, nil
+}
+
+// Monitors returns the monitors in configuration order.
+func (g *Group) Monitors() []*Monitor {
+	return g.monitors
+}
+
+// Monitor returns the first monitor of the server named name, or nil.
+func (g *Group) Monitor(name string) *Monitor {
+	return g.byName[name]
+}
+
+// Events returns a channel receiving every event of every server. Repeated
+// calls return the same channel. It is closed when all monitors stopped.
+func (g *Group) Events() <-chan Event {
+	g.once.Do(func() {
+		out := make(chan Event, 16)
+		var wg sync.WaitGroup
+		for _, m := range g.monitors {
+			wg.Add(1)
+			go func(in <-chan Event) {
+				defer wg.Done()
+				for ev := range in {
+					out <- ev
+				}
+			}(m.Events())
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		g.events = out
+	})
+	return g.events
+}
+
+// Run runs every monitor until ctx is cancelled. Each server has its own
+// connection and reconnect loop; a server that cannot be reached at first
+// is retried the same way. One that stops with an error, e.g. for a wrong
+// password, is logged and the others keep running. Run returns once all
+// of them stopped.
+func (g *Group) Run(ctx context.Context) error {
+	if len(g.monitors) == 1 {
+		return g.monitors[0].Run(ctx)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, m := range g.monitors {
+		m.retryFirst = true
+		wg.Add(1)
+		go func(m *Monitor) {
+			defer wg.Done()
+			if err := m.Run(ctx); err != nil {
+				source := m.Source()
+				log.Printf("❌ MPD %s stopped: %v", source, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", source, err))
+				mu.Unlock()
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// AlbumArt returns the cover art for uri from the named server.
+func (g *Group) AlbumArt(server, uri string) ([]byte, error) {
+	m := g.byName[server]
+	if m == nil {
+		return nil, fmt.Errorf("unknown MPD server %q", server)
+	}
+	return m.AlbumArt(uri)
+}
@@ -0,0 +1,119 @@
+package monitor_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestPartition(t *testing.T) {
+	srv := newServer(t)
+	cfg := config(srv)
+	cfg.Name, cfg.Partition = "home", "kitchen"
+	m := monitor.New(cfg)
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	if !slices.Contains(srv.Commands(), `partition "kitchen"`) {
+		t.Errorf("partition not selected: %q", srv.Commands())
+	}
+
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	snap := waitFor(t, events, monitor.KindSongChanged).Snapshot()
+	if snap.Source() != "home/kitchen" {
+		t.Errorf("Source = %q, want home/kitchen", snap.Source())
+	}
+}
+
+func TestGroupDuplicatePartition(t *testing.T) {
+	_, err := monitor.NewGroup(
+		monitor.Config{Name: "home", Partition: "kitchen"},
+		monitor.Config{Name: "home", Partition: "kitchen"},
+	)
+	if err == nil {
+		t.Error("NewGroup accepted the same partition twice")
+	}
+
+	_, err = monitor.NewGroup(
+		monitor.Config{Name: "home", Partition: "default"},
+		monitor.Config{Name: "home", Partition: "kitchen"},
+	)
+	if err != nil {
+		t.Errorf("NewGroup rejected two partitions: %v", err)
+	}
+}
+
+// startGroup runs g until the end of the test.
+func startGroup(t *testing.T, g *monitor.Group) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestGroupRetriesFirstConnect(t *testing.T) {
+	up, down := newServer(t), newServer(t)
+	down.Close()
+
+	a, b := config(up), config(down)
+	a.Name, b.Name = "a", "b"
+	g, err := monitor.NewGroup(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := g.Monitor("b").Events()
+	startGroup(t, g)
+
+	// The server that is down is retried until it comes up
+	waitFor(t, events, monitor.KindReconnecting)
+	if err := down.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, events, monitor.KindReconnected)
+	down.Play(mpd.Attrs{"file": "b.flac"})
+	waitFor(t, events, monitor.KindSongChanged)
+}
+
+func TestGroupStopsOnWrongPassword(t *testing.T) {
+	srvA, srvB := newServer(t), newServer(t)
+	srvB.SetPassword("secret")
+
+	a, b := config(srvA), config(srvB)
+	a.Name, b.Name = "a", "b"
+	b.Password = "wrong"
+	g, err := monitor.NewGroup(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventsA, eventsB := g.Monitor("a").Events(), g.Monitor("b").Events()
+	startGroup(t, g)
+
+	// b stops instead of retrying; a keeps running
+	timeout := time.After(eventTimeout)
+	for closed := false; !closed; {
+		select {
+		case ev, ok := <-eventsB:
+			if ev != nil && ev.Kind() == monitor.KindReconnecting {
+				t.Fatal("retrying with a wrong password")
+			}
+			closed = !ok
+		case <-timeout:
+			t.Fatal("monitor b kept running with a wrong password")
+		}
+	}
+	srvA.Play(mpd.Attrs{"file": "a.flac"})
+	waitFor(t, eventsA, monitor.KindSongChanged)
+}
@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// Album identifies an album in the library.
+type Album struct {
+	Artist string // album artist
+	Title  string
+}
+
+// library is the monitor's snapshot of the albums in the MPD database,
+// compared after each database update to tell what landed.
+type library struct {
+	loaded   bool
+	disabled bool // the server does not support grouped list
+	albums   map[Album]bool
+	artists  map[string]bool
+}
+
+// refresh loads the library and returns what was added since the previous
+// load. The first load reports nothing.
+func (l *library) refresh(conn *mpdproto.Conn) (albums []Album, artists []string, err error) {
+	if l.disabled {
+		return nil, nil, nil
+	}
+
+	resp, err := conn.Command("list", "album", "group", "albumartist")
+	if err != nil {
+		if Classify(err) == ClassProtocol {
+			// MPD before 0.21 has no "group"; do without the digest
+			l.disabled = true
+		}
+		return nil, nil, fmt.Errorf("failed to list albums: %w", err)
+	}
+
+	cur := make(map[Album]bool)
+	curArtists := make(map[string]bool)
+	var artist string
+	for _, p := range resp.Pairs {
+		switch p.Key {
+		case "AlbumArtist":
+			artist = p.Value
+			if artist != "" {
+				curArtists[artist] = true
+			}
+		case "Album":
+			if p.Value != "" {
+				cur[Album{Artist: artist, Title: p.Value}] = true
+			}
+		}
+	}
+
+	if l.loaded {
+		for a := range cur {
+			if !l.albums[a] {
+				albums = append(albums, a)
+			}
+		}
+		for a := range curArtists {
+			if !l.artists[a] {
+				artists = append(artists, a)
+			}
+		}
+		sort.Slice(albums, func(i, j int) bool {
+			if albums[i].Artist != albums[j].Artist {
+				return albums[i].Artist < albums[j].Artist
+			}
+			return albums[i].Title < albums[j].Title
+		})
+		sort.Strings(artists)
+	}
+
+	l.loaded, l.albums, l.artists = true, cur, curArtists
+	return albums, artists, nil
+}
@@ -0,0 +1,87 @@
+package monitor_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestDatabaseUpdate(t *testing.T) {
+	srv := newServer(t)
+	srv.AddAlbums("Artist A", "First")
+
+	m := monitor.New(config(srv))
+	events := m.Subscribe(monitor.KindUpdateStarted, monitor.KindUpdateFinished, monitor.KindLibraryDigest)
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Update(func(status, _ mpd.Attrs) { status["updating_db"] = "3" }, "update")
+	started := waitFor(t, events, monitor.KindUpdateStarted).(monitor.UpdateStarted)
+	if started.Job != 3 {
+		t.Errorf("started job %d, want 3", started.Job)
+	}
+
+	srv.AddAlbums("Artist A", "Second")
+	srv.AddAlbums("Artist B", "Third")
+	srv.Update(func(status, _ mpd.Attrs) { delete(status, "updating_db") }, "update", "database")
+	finished := waitFor(t, events, monitor.KindUpdateFinished).(monitor.UpdateFinished)
+	if finished.Job != 3 || finished.Duration <= 0 {
+		t.Errorf("finished job %d after %v, want job 3 with a duration", finished.Job, finished.Duration)
+	}
+
+	digest := waitFor(t, events, monitor.KindLibraryDigest).(monitor.LibraryDigest)
+	want := []monitor.Album{{Artist: "Artist A", Title: "Second"}, {Artist: "Artist B", Title: "Third"}}
+	if !slices.Equal(digest.Albums, want) {
+		t.Errorf("new albums %v, want %v", digest.Albums, want)
+	}
+	if !slices.Equal(digest.Artists, []string{"Artist B"}) {
+		t.Errorf("new artists %q, want [Artist B]", digest.Artists)
+	}
+}
+
+func TestNoDatabase(t *testing.T) {
+	srv := newServer(t)
+	srv.AddAlbums("Artist A", "First")
+
+	cfg := config(srv)
+	cfg.NoDatabase = true
+	m := monitor.New(cfg)
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	// An update running while the player changes shows in the status
+	srv.Update(func(status, _ mpd.Attrs) { status["updating_db"] = "3" }, "update")
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	srv.AddAlbums("Artist B", "Second")
+	srv.Update(func(status, _ mpd.Attrs) { delete(status, "updating_db") }, "update", "database")
+	srv.Play(mpd.Attrs{"file": "b.flac"})
+
+	timeout := time.After(eventTimeout)
+	for {
+		var ev monitor.Event
+		select {
+		case ev = <-events:
+		case <-timeout:
+			t.Fatal("no SongChanged event")
+		}
+		switch e := ev.(type) {
+		case monitor.UpdateStarted, monitor.UpdateFinished, monitor.LibraryDigest:
+			t.Fatalf("got %s with NoDatabase", ev.Kind())
+		case monitor.SongChanged:
+			if e.New["file"] == "b.flac" {
+				for _, cmd := range srv.Commands() {
+					if strings.HasPrefix(cmd, "list") {
+						t.Errorf("library loaded with %q", cmd)
+					}
+				}
+				return
+			}
+		}
+	}
+}
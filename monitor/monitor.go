@@ -0,0 +1,295 @@
+// Package monitor watches an MPD server and reports player changes as events.
+//
+// A Monitor holds a single MPD connection that alternates between idle and
+// command batches, and reconnects on its own; programs embedding it only
+// need to call Run and consume Events.
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// Config holds the MPD connection settings of a Monitor.
+type Config struct {
+	// Name labels the server in events when several are monitored. It may
+	// be empty for a single server.
+	Name string
+
+	// Host is a hostname, a Unix socket path starting with / or an abstract
+	// socket name starting with @. If empty, the usual socket locations are
+	// searched before falling back to localhost.
+	Host     string
+	Port     string
+	Password string
+	Timeout  int // seconds, for dialing and for each command; 0 disables
+	Debug    bool
+
+	// Partition selects an MPD partition (MPD 0.22+) for the connection.
+	// Empty means the default partition.
+	Partition string
+
+	// Direct makes requests such as Outputs dial a connection of their
+	// own instead of going through Run's, for programs that send a few
+	// commands without watching the player. Run is not needed then.
+	Direct bool
+
+	// NoDatabase leaves database updates and the library digest to another
+	// monitor of the same server. The database is shared by all
+	// partitions, so only one of them should report it.
+	NoDatabase bool
+
+	// Channel is a client-to-client channel to subscribe to. Messages sent
+	// to it by other MPD clients arrive as MessageReceived events.
+	Channel string
+
+	// ClearErrors runs clearerror once an MPD error has been reported as
+	// ErrorRaised, so that the next failure is reported as well.
+	ClearErrors bool
+
+	// UpNext is how long before the current song ends an UpNext event
+	// announces the next one; crossfade is taken into account. Zero
+	// disables it.
+	UpNext time.Duration
+
+	// Reconnect controls the delays between reconnect attempts. Unset
+	// delays and multiplier take their value from DefaultReconnectPolicy.
+	Reconnect ReconnectPolicy
+}
+
+// Monitor watches a single MPD server.
+type Monitor struct {
+	config Config
+
+	subMu  sync.Mutex // guards subs and all
+	subs   []*subscription
+	all    <-chan Event
+	closed bool
+
+	// requests carries work from other goroutines to the connection loop,
+	// which runs it between two idles.
+	requests chan request
+
+	backoff *backoff
+
+	last    Snapshot
+	queue   queue
+	library library
+	outputs []Output // nil until loaded
+	// updateStart is when the running database update was first seen.
+	updateStart time.Time
+	// announced is the upNextKey of the last UpNext event.
+	announced string
+	// retryFirst makes Run retry a failed first connect under the
+	// reconnect policy instead of returning, so that a server that is
+	// down does not stop a Group.
+	retryFirst bool
+}
+
+type subscription struct {
+	ch    chan Event
+	kinds map[Kind]bool // nil means every kind
+}
+
+// New returns a Monitor for the server described by config. No connection
+// is made until Run is called.
+func New(config Config) *Monitor {
+	return &Monitor{
+		config:   config,
+		requests: make(chan request),
+		backoff:  newBackoff(config.Reconnect),
+		last:     Snapshot{Server: config.Name, Partition: config.Partition},
+	}
+}
+
+// Events returns a channel receiving every event. Repeated calls return the
+// same channel. It is closed when Run returns.
+func (m *Monitor) Events() <-chan Event {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	if m.all == nil {
+		m.all = m.subscribe(nil)
+	}
+	return m.all
+}
+
+// Subscribe returns a new channel receiving only events of the given kinds,
+// or every event if none are given. Subscribers must keep reading: the
+// monitor waits for each one before reporting further changes. The channel
+// is closed when Run returns.
+func (m *Monitor) Subscribe(kinds ...Kind) <-chan Event {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	return m.subscribe(kinds)
+}
+
+func (m *Monitor) subscribe(kinds []Kind) <-chan Event {
+	sub := &subscription{ch: make(chan Event, 16)}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[Kind]bool, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+
+	if m.closed {
+		close(sub.ch)
+	} else {
+		m.subs = append(m.subs, sub)
+	}
+	return sub.ch
+}
+
+func (m *Monitor) closeSubscriptions() {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	for _, sub := range m.subs {
+		close(sub.ch)
+	}
+	m.subs = nil
+	m.closed = true
+}
+
+// Name returns the server name from the Config.
+func (m *Monitor) Name() string {
+	return m.config.Name
+}
+
+// Partition returns the partition from the Config.
+func (m *Monitor) Partition() string {
+	return m.config.Partition
+}
+
+// Source returns the label its events carry in Snapshot.Source.
+func (m *Monitor) Source() string {
+	return Snapshot{Server: m.config.Name, Partition: m.config.Partition}.Source()
+}
+
+// Addr returns the address of the monitored server: host:port or a socket path.
+func (m *Monitor) Addr() string {
+	_, addr := m.config.Endpoint()
+	return addr
+}
+
+// Backoff returns the state of the reconnect cycle.
+func (m *Monitor) Backoff() BackoffState {
+	return m.backoff.current()
+}
+
+// AlbumArt returns the cover art for uri, trying embedded artwork first and
+// falling back to a cover file in the song's directory. It runs on the
+// monitor's connection, so it fails while Run is not connected.
+func (m *Monitor) AlbumArt(uri string) ([]byte, error) {
+	var artwork []byte
+	err := m.do(func(conn *mpdproto.Conn) error {
+		// Ask for embedded and external artwork in one round trip
+		resps, err := conn.CommandList(
+			mpdproto.NewCmd("readpicture", uri, "0"),
+			mpdproto.NewCmd("albumart", uri, "0"),
+		)
+		if isConnectionError(err) {
+			return err
+		}
+
+		switch {
+		case len(resps) > 0 && len(resps[0].Binary) > 0:
+			// Prefer the embedded picture
+			artwork, err = conn.BinaryRest("readpicture", uri, resps[0])
+		case len(resps) == 2:
+			artwork, err = conn.BinaryRest("albumart", uri, resps[1])
+		case len(resps) == 0:
+			// readpicture failed, e.g. on MPD before 0.22
+			artwork, err = conn.Binary("albumart", uri)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return artwork, nil
+}
+
+// Run connects to MPD and reports changes until ctx is cancelled. It
+// reconnects on connection errors and only returns early if the first
+// connection cannot be made, the reconnect policy gives up, or a
+// non-connection error occurs.
+func (m *Monitor) Run(ctx context.Context) error {
+	defer m.closeSubscriptions()
+
+	conn, err := m.connect()
+	if err != nil {
+		if !m.retryFirst || Classify(err) == ClassAuth {
+			return err
+		}
+		if conn, err = m.reconnect(ctx, err); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+
+	// Main monitoring loop with reconnection
+	for {
+		err := m.session(ctx, conn)
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if m.config.Debug {
+			log.Printf("❌ Monitor error: %v", err)
+		}
+
+		// Only transport failures warrant a reconnect
+		if !isConnectionError(err) {
+			return err
+		}
+
+		conn, err = m.reconnect(ctx, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// emit delivers ev to every subscriber interested in its kind.
+func (m *Monitor) emit(ctx context.Context, ev Event) {
+	m.subMu.Lock()
+	subs := m.subs
+	m.subMu.Unlock()
+
+	for _, sub := range subs {
+		if sub.kinds != nil && !sub.kinds[ev.Kind()] {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, reporting whether the full
+// duration elapsed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
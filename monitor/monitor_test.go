@@ -0,0 +1,183 @@
+package monitor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/mpdtest"
+)
+
+// eventTimeout bounds every wait for an event.
+const eventTimeout = 5 * time.Second
+
+// fastReconnect retries quickly so reconnect tests finish fast.
+var fastReconnect = monitor.ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+func newServer(t *testing.T) *mpdtest.Server {
+	t.Helper()
+	srv, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// config returns a Config for srv.
+func config(srv *mpdtest.Server) monitor.Config {
+	return monitor.Config{Host: srv.Host(), Port: srv.Port(), Timeout: 2, Reconnect: fastReconnect}
+}
+
+// start runs m until the end of the test and returns the channel Run's
+// result arrives on. Subscribe before calling it.
+func start(t *testing.T, m *monitor.Monitor) <-chan error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		for range done {
+		}
+	})
+	return done
+}
+
+// waitIdle waits until the last command a client sent srv is idle, i.e.
+// the monitor has caught up and waits for the next change.
+func waitIdle(t *testing.T, srv *mpdtest.Server) {
+	t.Helper()
+	deadline := time.Now().Add(eventTimeout)
+	for time.Now().Before(deadline) {
+		if cmds := srv.Commands(); len(cmds) > 0 && strings.HasPrefix(cmds[len(cmds)-1], "idle") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("monitor did not go idle")
+}
+
+// waitFor returns the next event of kind from ch, skipping others.
+func waitFor(t *testing.T, ch <-chan monitor.Event, kind monitor.Kind) monitor.Event {
+	t.Helper()
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatalf("events closed while waiting for %s", kind)
+			}
+			if ev.Kind() == kind {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", kind)
+		}
+	}
+}
+
+func TestSongAndStateChanges(t *testing.T) {
+	srv := newServer(t)
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Play(mpd.Attrs{"file": "a.flac", "Title": "A"})
+	song := waitFor(t, events, monitor.KindSongChanged).(monitor.SongChanged)
+	if song.New["file"] != "a.flac" {
+		t.Errorf("New file = %q, want a.flac", song.New["file"])
+	}
+
+	srv.SetState("pause")
+	state := waitFor(t, events, monitor.KindStateChanged).(monitor.StateChanged)
+	if state.Old != "play" || state.New != "pause" {
+		t.Errorf("state %s -> %s, want play -> pause", state.Old, state.New)
+	}
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	srv := newServer(t)
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.ResetCommands()
+	srv.Disconnect()
+	waitFor(t, events, monitor.KindReconnecting)
+	waitFor(t, events, monitor.KindReconnected)
+	waitIdle(t, srv)
+
+	// Changes are seen on the new connection
+	srv.Play(mpd.Attrs{"file": "b.flac"})
+	song := waitFor(t, events, monitor.KindSongChanged).(monitor.SongChanged)
+	if song.New["file"] != "b.flac" {
+		t.Errorf("New file = %q, want b.flac", song.New["file"])
+	}
+}
+
+func TestReconnectAfterRestart(t *testing.T) {
+	srv := newServer(t)
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+	m := monitor.New(config(srv))
+	events := m.Events()
+	start(t, m)
+	waitFor(t, events, monitor.KindSongChanged)
+	waitIdle(t, srv)
+
+	srv.ResetCommands()
+	if err := srv.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	reconnected := waitFor(t, events, monitor.KindReconnected).(monitor.Reconnected)
+	if reconnected.Attempts < 1 {
+		t.Errorf("Attempts = %d, want at least 1", reconnected.Attempts)
+	}
+	waitIdle(t, srv)
+
+	srv.SetState("pause")
+	waitFor(t, events, monitor.KindStateChanged)
+	if state := m.Backoff(); state.Reconnecting {
+		t.Errorf("backoff still reconnecting: %s", state)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	srv := newServer(t)
+	srv.SetPassword("secret")
+	srv.Play(mpd.Attrs{"file": "a.flac"})
+
+	cfg := config(srv)
+	cfg.Password = "secret"
+	m := monitor.New(cfg)
+	events := m.Events()
+	start(t, m)
+	waitFor(t, events, monitor.KindSongChanged)
+}
+
+func TestWrongPassword(t *testing.T) {
+	srv := newServer(t)
+	srv.SetPassword("secret")
+
+	cfg := config(srv)
+	cfg.Password = "wrong"
+	done := start(t, monitor.New(cfg))
+
+	select {
+	case err := <-done:
+		if monitor.Classify(err) != monitor.ClassAuth {
+			t.Errorf("Run error %v has class %s, want auth", err, monitor.Classify(err))
+		}
+	case <-time.After(eventTimeout):
+		t.Fatal("Run kept going with a wrong password")
+	}
+}
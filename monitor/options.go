@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"strconv"
+	"time"
+)
+
+// Options are the playback modes of a player.
+type Options struct {
+	Repeat     bool
+	Random     bool
+	Single     string // "0", "1" or "oneshot"
+	Consume    string // "0", "1" or "oneshot"
+	Crossfade  time.Duration
+	ReplayGain string // "off", "track", "album" or "auto"; empty if unknown
+}
+
+// Options returns the playback modes in effect when the snapshot was taken.
+func (s Snapshot) Options() Options {
+	var xfade time.Duration
+	if sec, err := strconv.ParseFloat(s.Status["xfade"], 64); err == nil {
+		xfade = time.Duration(sec * float64(time.Second))
+	}
+
+	return Options{
+		Repeat:     s.Status["repeat"] == "1",
+		Random:     s.Status["random"] == "1",
+		Single:     orZero(s.Status["single"]),
+		Consume:    orZero(s.Status["consume"]),
+		Crossfade:  xfade,
+		ReplayGain: s.ReplayGain,
+	}
+}
+
+func orZero(mode string) string {
+	if mode == "" {
+		return "0"
+	}
+	return mode
+}
@@ -0,0 +1,37 @@
+package monitor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestOptionsChanged(t *testing.T) {
+	srv := newServer(t)
+	m := monitor.New(config(srv))
+	events := m.Subscribe(monitor.KindOptionsChanged)
+	start(t, m)
+	waitIdle(t, srv)
+
+	srv.Update(func(status, _ mpd.Attrs) {
+		status["repeat"] = "1"
+		status["xfade"] = "5"
+	}, "options")
+	e := waitFor(t, events, monitor.KindOptionsChanged).(monitor.OptionsChanged)
+	if e.Old.Repeat || !e.New.Repeat {
+		t.Errorf("repeat %v -> %v, want false -> true", e.Old.Repeat, e.New.Repeat)
+	}
+	if e.New.Crossfade != 5*time.Second {
+		t.Errorf("Crossfade = %v, want 5s", e.New.Crossfade)
+	}
+
+	// The replay gain mode comes from replay_gain_status
+	srv.SetReplayGain("album")
+	e = waitFor(t, events, monitor.KindOptionsChanged).(monitor.OptionsChanged)
+	if e.New.ReplayGain != "album" {
+		t.Errorf("ReplayGain = %q, want album", e.New.ReplayGain)
+	}
+}
@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// Output is an MPD audio output.
+type Output struct {
+	ID      int
+	Name    string
+	Plugin  string
+	Enabled bool
+}
+
+// ErrNoOutput is returned by SwitchOutput when no output matches.
+var ErrNoOutput = errors.New("no such output")
+
+// OutputAction is what SwitchOutput does to an output.
+type OutputAction int
+
+const (
+	OutputEnable OutputAction = iota
+	OutputDisable
+	OutputToggle
+)
+
+func fetchOutputs(conn *mpdproto.Conn) ([]Output, error) {
+	resp, err := conn.Command("outputs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outputs: %w", err)
+	}
+
+	outputs := []Output{}
+	for _, attrs := range resp.List("outputid") {
+		outputs = append(outputs, Output{
+			ID:      atoi(attrs["outputid"], -1),
+			Name:    attrs["outputname"],
+			Plugin:  attrs["plugin"],
+			Enabled: attrs["outputenabled"] == "1",
+		})
+	}
+	return outputs, nil
+}
+
+// diffOutputs reports outputs that were switched on or off. Outputs that
+// appear or disappear, e.g. with a partition change, are not reported.
+func diffOutputs(old, cur []Output, h Header) []Event {
+	was := make(map[int]bool, len(old))
+	for _, o := range old {
+		was[o.ID] = o.Enabled
+	}
+
+	var events []Event
+	for _, o := range cur {
+		if enabled, ok := was[o.ID]; ok && enabled != o.Enabled {
+			events = append(events, OutputChanged{Header: h, Output: o})
+		}
+	}
+	return events
+}
+
+// Outputs lists the audio outputs, using the monitor's connection.
+func (m *Monitor) Outputs() ([]Output, error) {
+	var outputs []Output
+	err := m.do(func(conn *mpdproto.Conn) (err error) {
+		outputs, err = fetchOutputs(conn)
+		return err
+	})
+	return outputs, err
+}
+
+// EnableOutput switches the output with the given id on or off, using the
+// monitor's connection.
+func (m *Monitor) EnableOutput(id int, enabled bool) error {
+	cmd := "disableoutput"
+	if enabled {
+		cmd = "enableoutput"
+	}
+	return m.do(func(conn *mpdproto.Conn) error {
+		_, err := conn.Command(cmd, strconv.Itoa(id))
+		return err
+	})
+}
+
+// SwitchOutput looks an output up with find among the current outputs and
+// applies action to it, both in one request: on the monitor's connection,
+// or on a single connection of its own under Config.Direct. It returns the
+// output as it was before the switch.
+func (m *Monitor) SwitchOutput(find func([]Output) (Output, bool), action OutputAction) (Output, error) {
+	var o Output
+	err := m.do(func(conn *mpdproto.Conn) error {
+		outputs, err := fetchOutputs(conn)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if o, ok = find(outputs); !ok {
+			return ErrNoOutput
+		}
+
+		cmd := "toggleoutput"
+		switch action {
+		case OutputEnable:
+			cmd = "enableoutput"
+		case OutputDisable:
+			cmd = "disableoutput"
+		}
+		_, err = conn.Command(cmd, strconv.Itoa(o.ID))
+		return err
+	})
+	return o, err
+}
+
+// ToggleOutput switches the output with the given id to the opposite
+// state, using the monitor's connection.
+func (m *Monitor) ToggleOutput(id int) error {
+	return m.do(func(conn *mpdproto.Conn) error {
+		_, err := conn.Command("toggleoutput", strconv.Itoa(id))
+		return err
+	})
+}
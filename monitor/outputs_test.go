@@ -0,0 +1,125 @@
+package monitor_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestOutputs(t *testing.T) {
+	srv := newServer(t)
+	srv.AddOutput("USB DAC", "alsa", true)
+	srv.AddOutput("Stream", "httpd", false)
+
+	m := monitor.New(config(srv))
+	events := m.Subscribe(monitor.KindOutputChanged)
+	start(t, m)
+
+	outputs, err := m.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []monitor.Output{
+		{ID: 0, Name: "USB DAC", Plugin: "alsa", Enabled: true},
+		{ID: 1, Name: "Stream", Plugin: "httpd", Enabled: false},
+	}
+	if len(outputs) != len(want) || outputs[0] != want[0] || outputs[1] != want[1] {
+		t.Errorf("outputs = %+v, want %+v", outputs, want)
+	}
+
+	if err := m.ToggleOutput(1); err != nil {
+		t.Fatal(err)
+	}
+	if e := waitFor(t, events, monitor.KindOutputChanged).(monitor.OutputChanged); e.Output.ID != 1 || !e.Output.Enabled {
+		t.Errorf("changed %+v, want output 1 enabled", e.Output)
+	}
+
+	// Switches by other clients are reported too
+	srv.SetOutput(0, false)
+	if e := waitFor(t, events, monitor.KindOutputChanged).(monitor.OutputChanged); e.Output.ID != 0 || e.Output.Enabled {
+		t.Errorf("changed %+v, want output 0 disabled", e.Output)
+	}
+
+	if err := m.EnableOutput(5, true); monitor.Classify(err) != monitor.ClassProtocol {
+		t.Errorf("enabling a missing output: %v, want an MPD error", err)
+	}
+}
+
+func TestDirectOutputs(t *testing.T) {
+	srv := newServer(t)
+	srv.AddOutput("USB DAC", "alsa", true)
+
+	cfg := config(srv)
+	cfg.Direct = true
+	m := monitor.New(cfg)
+
+	// No Run: each request dials its own connection
+	if err := m.EnableOutput(0, false); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := m.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || outputs[0].Enabled {
+		t.Errorf("outputs = %+v, want USB DAC disabled", outputs)
+	}
+
+	for _, cmd := range srv.Commands() {
+		if cmd != "outputs" && cmd != `disableoutput "0"` {
+			t.Errorf("unexpected command %q", cmd)
+		}
+	}
+}
+
+func TestSwitchOutputOnOneConnection(t *testing.T) {
+	srv := newServer(t)
+	srv.SetPassword("secret")
+	srv.AddOutput("USB DAC", "alsa", true)
+	srv.AddOutput("Stream", "httpd", false)
+
+	cfg := config(srv)
+	cfg.Password, cfg.Direct = "secret", true
+	m := monitor.New(cfg)
+
+	byName := func(name string) func([]monitor.Output) (monitor.Output, bool) {
+		return func(outputs []monitor.Output) (monitor.Output, bool) {
+			i := slices.IndexFunc(outputs, func(o monitor.Output) bool { return o.Name == name })
+			if i < 0 {
+				return monitor.Output{}, false
+			}
+			return outputs[i], true
+		}
+	}
+
+	o, err := m.SwitchOutput(byName("Stream"), monitor.OutputToggle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ID != 1 || o.Enabled {
+		t.Errorf("switched %+v, want the disabled Stream", o)
+	}
+
+	// Every connection authenticates once: the lookup and the switch
+	// shared one
+	cmds := srv.Commands()
+	conns := 0
+	for _, cmd := range cmds {
+		if strings.HasPrefix(cmd, "password") {
+			conns++
+		}
+	}
+	if conns != 1 {
+		t.Errorf("%d connections in %q, want 1", conns, cmds)
+	}
+	if !slices.Contains(cmds, `toggleoutput "1"`) {
+		t.Errorf("output not toggled: %q", cmds)
+	}
+
+	if _, err := m.SwitchOutput(byName("HDMI"), monitor.OutputEnable); !errors.Is(err, monitor.ErrNoOutput) {
+		t.Errorf("switching a missing output: %v, want ErrNoOutput", err)
+	}
+}
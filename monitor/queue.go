@@ -0,0 +1,166 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// Move is a queue entry that changed its place relative to the others.
+type Move struct {
+	Track    mpd.Attrs
+	From, To int // positions before and after
+}
+
+// queue is the monitor's copy of the MPD queue, kept current with
+// plchanges so that only changed entries cross the wire.
+type queue struct {
+	loaded  bool
+	version int
+	tracks  []mpd.Attrs // by position
+}
+
+// update brings q to cur's playlist version and returns the events
+// describing the difference. The first load reports nothing, like the
+// first snapshot.
+func (q *queue) update(conn *mpdproto.Conn, cur Snapshot) ([]Event, error) {
+	version, length := cur.PlaylistVersion(), cur.PlaylistLength()
+	if q.loaded && version == q.version {
+		return nil, nil
+	}
+
+	if !q.loaded {
+		tracks, err := fetchQueue(conn)
+		if err != nil {
+			return nil, err
+		}
+		q.loaded, q.version, q.tracks = true, version, tracks
+		return nil, nil
+	}
+
+	resp, err := conn.Command("plchanges", strconv.Itoa(q.version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue changes: %w", err)
+	}
+
+	tracks := make([]mpd.Attrs, length)
+	copy(tracks, q.tracks)
+	for _, entry := range resp.List("file") {
+		if pos, err := strconv.Atoi(entry["Pos"]); err == nil && pos >= 0 && pos < length {
+			tracks[pos] = entry
+		}
+	}
+	for _, t := range tracks {
+		if t == nil {
+			// The changes do not add up, e.g. after an MPD restart
+			// reset the version; start over from the full queue
+			if tracks, err = fetchQueue(conn); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	old := q.tracks
+	q.version, q.tracks = version, tracks
+	return diffQueue(old, tracks, Header{Current: cur}), nil
+}
+
+func fetchQueue(conn *mpdproto.Conn) ([]mpd.Attrs, error) {
+	resp, err := conn.Command("playlistinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue: %w", err)
+	}
+	return resp.List("file"), nil
+}
+
+// diffQueue compares two versions of the queue by song id. Entries that
+// only shifted because others were added or removed do not count as moved.
+func diffQueue(old, cur []mpd.Attrs, h Header) []Event {
+	oldPos := make(map[string]int, len(old))
+	for pos, t := range old {
+		oldPos[t["Id"]] = pos
+	}
+	curIDs := make(map[string]bool, len(cur))
+	for _, t := range cur {
+		curIDs[t["Id"]] = true
+	}
+
+	var events []Event
+
+	var removed []mpd.Attrs
+	for _, t := range old {
+		if !curIDs[t["Id"]] {
+			removed = append(removed, t)
+		}
+	}
+	switch {
+	case len(old) > 0 && len(removed) == len(old):
+		events = append(events, QueueCleared{Header: h, Tracks: old})
+	case len(removed) > 0:
+		events = append(events, TracksRemoved{Header: h, Tracks: removed})
+	}
+
+	// Surviving entries in their new order, with their old positions
+	var kept []mpd.Attrs
+	var keptOld, keptNew []int
+	var added []mpd.Attrs
+	for pos, t := range cur {
+		if from, ok := oldPos[t["Id"]]; ok {
+			kept = append(kept, t)
+			keptOld = append(keptOld, from)
+			keptNew = append(keptNew, pos)
+		} else {
+			added = append(added, t)
+		}
+	}
+	if len(added) > 0 {
+		events = append(events, TracksAdded{Header: h, Tracks: added})
+	}
+
+	// The longest run of survivors still in their old order stayed put;
+	// everything else was moved
+	stayed := increasing(keptOld)
+	var moves []Move
+	for i, t := range kept {
+		if !stayed[i] {
+			moves = append(moves, Move{Track: t, From: keptOld[i], To: keptNew[i]})
+		}
+	}
+	if len(moves) > 0 {
+		events = append(events, TracksMoved{Header: h, Moves: moves})
+	}
+
+	return events
+}
+
+// increasing marks the members of a longest strictly increasing
+// subsequence of seq.
+func increasing(seq []int) []bool {
+	tails := []int{}              // indices into seq: smallest tail of each length
+	prev := make([]int, len(seq)) // predecessor in the subsequence
+	for i, v := range seq {
+		n := sort.Search(len(tails), func(j int) bool { return seq[tails[j]] >= v })
+		prev[i] = -1
+		if n > 0 {
+			prev[i] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[n] = i
+		}
+	}
+
+	in := make([]bool, len(seq))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			in[i] = true
+		}
+	}
+	return in
+}
@@ -0,0 +1,70 @@
+package monitor_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+// entry returns a queue entry for file, keeping its place in the queue if
+// id is set.
+func entry(id, file string) mpd.Attrs {
+	e := mpd.Attrs{"file": file, "Title": file}
+	if id != "" {
+		e["Id"] = id
+	}
+	return e
+}
+
+func files(tracks []mpd.Attrs) []string {
+	var names []string
+	for _, t := range tracks {
+		names = append(names, t["file"])
+	}
+	return names
+}
+
+func TestQueueChanges(t *testing.T) {
+	srv := newServer(t)
+	srv.SetQueue(entry("", "1"), entry("", "2"), entry("", "3"), entry("", "4")) // ids 1 to 4
+
+	m := monitor.New(config(srv))
+	events := m.Subscribe(monitor.KindTracksAdded, monitor.KindTracksRemoved, monitor.KindTracksMoved, monitor.KindQueueCleared)
+	start(t, m)
+	waitIdle(t, srv)
+	srv.ResetCommands()
+
+	srv.SetQueue(entry("1", "1"), entry("3", "3"), entry("4", "4"), entry("", "5"), entry("", "6"))
+	removed := waitFor(t, events, monitor.KindTracksRemoved).(monitor.TracksRemoved)
+	if got := files(removed.Tracks); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("removed %q, want [2]", got)
+	}
+	added := waitFor(t, events, monitor.KindTracksAdded).(monitor.TracksAdded)
+	if got := files(added.Tracks); !slices.Equal(got, []string{"5", "6"}) {
+		t.Errorf("added %q, want [5 6]", got)
+	}
+
+	// Entries shifted by the others do not count as moved
+	srv.SetQueue(entry("4", "4"), entry("1", "1"), entry("3", "3"), entry("5", "5"), entry("6", "6"))
+	moved := waitFor(t, events, monitor.KindTracksMoved).(monitor.TracksMoved)
+	if len(moved.Moves) != 1 || moved.Moves[0].Track["file"] != "4" || moved.Moves[0].From != 2 || moved.Moves[0].To != 0 {
+		t.Errorf("moves = %+v, want 4 from 2 to 0", moved.Moves)
+	}
+
+	srv.SetQueue()
+	cleared := waitFor(t, events, monitor.KindQueueCleared).(monitor.QueueCleared)
+	if len(cleared.Tracks) != 5 {
+		t.Errorf("cleared %d tracks, want 5", len(cleared.Tracks))
+	}
+
+	// Only the changes crossed the wire
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, "playlistinfo") {
+			t.Errorf("queue reloaded with %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/internal/mpdproto"
+)
+
+// songEnd returns when the current song of s hands over to the next one:
+// its end, or the start of the crossfade. It reports false if that cannot
+// be told, e.g. for streams.
+func songEnd(s Snapshot) (time.Time, bool) {
+	d := s.Duration()
+	if s.State() != "play" || d <= 0 {
+		return time.Time{}, false
+	}
+	return s.Time.Add(d - s.Elapsed() - s.Options().Crossfade), true
+}
+
+// upNextKey identifies the pair of songs an UpNext event is about, so each
+// pair is announced once.
+func upNextKey(s Snapshot) string {
+	return s.Status["songid"] + ">" + s.Status["nextsongid"]
+}
+
+// upNextAt returns when to announce the next song of s, or the zero time
+// if there is nothing to announce: the feature is off, playback stops or
+// repeats the same song after this one, or it was announced already.
+func (m *Monitor) upNextAt(s Snapshot) time.Time {
+	if m.config.UpNext <= 0 {
+		return time.Time{}
+	}
+
+	next := s.Status["nextsongid"]
+	if next == "" || next == s.Status["songid"] || upNextKey(s) == m.announced {
+		return time.Time{}
+	}
+	if o := s.Options(); o.Single != "0" && !o.Repeat {
+		// Playback stops after this song
+		return time.Time{}
+	}
+
+	end, ok := songEnd(s)
+	if !ok {
+		return time.Time{}
+	}
+	return end.Add(-m.config.UpNext)
+}
+
+// announceNext emits UpNext once the current song is within
+// Config.UpNext of its end, looking the next song up with playlistid.
+func (m *Monitor) announceNext(ctx context.Context, conn *mpdproto.Conn, now time.Time) error {
+	cur := m.last
+	at := m.upNextAt(cur)
+	if at.IsZero() || now.Before(at) {
+		return nil
+	}
+	m.announced = upNextKey(cur)
+
+	end, _ := songEnd(cur)
+	if !now.Before(end) {
+		// Too late to be of use
+		return nil
+	}
+
+	resp, err := conn.Command("playlistid", cur.Status["nextsongid"])
+	if err != nil {
+		return fmt.Errorf("failed to get the next song: %w", err)
+	}
+
+	m.emit(ctx, UpNext{Header: Header{Current: cur}, Next: resp.Attrs(), Remaining: end.Sub(now)})
+	return nil
+}
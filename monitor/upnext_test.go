@@ -0,0 +1,64 @@
+package monitor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+func TestUpNext(t *testing.T) {
+	srv := newServer(t)
+	srv.SetQueue(mpd.Attrs{"file": "a.flac"}, mpd.Attrs{"file": "b.flac"})
+	srv.Play(mpd.Attrs{"file": "a.flac", "Id": "1", "duration": "10"})
+	// 3s left with a 2s crossfade: the next song starts in 1s
+	srv.Update(func(status, _ mpd.Attrs) {
+		status["elapsed"] = "7.000"
+		status["xfade"] = "2"
+		status["nextsongid"] = "2"
+	}, "player")
+
+	cfg := config(srv)
+	cfg.UpNext = 500 * time.Millisecond
+	m := monitor.New(cfg)
+	events := m.Subscribe(monitor.KindUpNext)
+	start(t, m)
+
+	next := waitFor(t, events, monitor.KindUpNext).(monitor.UpNext)
+	if next.Next["file"] != "b.flac" {
+		t.Errorf("Next file = %q, want b.flac", next.Next["file"])
+	}
+	if next.Remaining <= 0 || next.Remaining > cfg.UpNext {
+		t.Errorf("Remaining = %s, want up to %s", next.Remaining, cfg.UpNext)
+	}
+
+	// The same pair of songs is announced once
+	srv.Notify("player")
+	select {
+	case ev := <-events:
+		t.Errorf("announced again: %v", ev)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestNoUpNextBeforeLastSong(t *testing.T) {
+	srv := newServer(t)
+	srv.Play(mpd.Attrs{"file": "a.flac", "duration": "10"})
+	srv.Update(func(status, _ mpd.Attrs) { status["elapsed"] = "9.500" }, "player")
+
+	cfg := config(srv)
+	cfg.UpNext = 2 * time.Second
+	m := monitor.New(cfg)
+	events := m.Subscribe(monitor.KindUpNext)
+	start(t, m)
+	waitIdle(t, srv)
+
+	// Without a next song there is nothing to announce
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected %v", ev)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,944 @@
+// Package mpdtest provides an in-process fake MPD server for tests.
+//
+// The server speaks enough of the MPD protocol for the monitor (idle,
+// noidle, command lists, status, currentsong, readpicture, albumart, ping,
+// password, partition, replay_gain_status, clearerror, playlistinfo,
+// plchanges, playlistid, list album group albumartist, outputs,
+// enableoutput, disableoutput, toggleoutput, subscribe, unsubscribe,
+// readmessages, sendmessage) and lets a test script the player: change
+// songs and state, stall replies, drop client connections or restart the
+// whole daemon.
+package mpdtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// Version is the protocol version announced to clients.
+const Version = "0.23.5"
+
+// chunkSize is the largest binary chunk sent by readpicture and albumart.
+const chunkSize = 8192
+
+// ACK error codes used by the server.
+const (
+	ackArg        = 2
+	ackPassword   = 3
+	ackPermission = 4
+	ackUnknown    = 5
+	ackNoExist    = 50
+)
+
+// Server is a fake MPD server listening on a loopback TCP port or a Unix
+// socket.
+type Server struct {
+	mu       sync.Mutex
+	network  string
+	addr     string
+	ln       net.Listener
+	clients  map[*client]bool
+	password string
+	status   mpd.Attrs
+	song     mpd.Attrs
+	rgMode   string
+	queue    []queueEntry
+	library  map[string][]string // album artist -> albums
+	outputs  []output
+	pictures map[string]picture
+	art      map[string]picture
+	failures map[string]string
+	stalls   map[string]time.Duration
+	commands []string
+	songID   int
+	wg       sync.WaitGroup
+}
+
+// queueEntry is a song in the queue and the playlist version that last
+// changed its position.
+type queueEntry struct {
+	song    mpd.Attrs
+	version int
+}
+
+type output struct {
+	name, plugin string
+	enabled      bool
+}
+
+type picture struct {
+	data []byte
+	typ  string
+}
+
+// NewServer starts a server on a random loopback port with a stopped
+// player and an empty queue.
+func NewServer() (*Server, error) {
+	return listen("tcp", "127.0.0.1:0")
+}
+
+// NewUnixServer is like NewServer but listens on a Unix socket at path.
+func NewUnixServer(path string) (*Server, error) {
+	return listen("unix", path)
+}
+
+func listen(network, addr string) (*Server, error) {
+	s := &Server{
+		clients: make(map[*client]bool),
+		status: mpd.Attrs{
+			"volume":         "100",
+			"repeat":         "0",
+			"random":         "0",
+			"single":         "0",
+			"consume":        "0",
+			"playlist":       "1",
+			"playlistlength": "0",
+			"state":          "stop",
+		},
+		song:     mpd.Attrs{},
+		rgMode:   "off",
+		library:  make(map[string][]string),
+		pictures: make(map[string]picture),
+		art:      make(map[string]picture),
+		failures: make(map[string]string),
+		stalls:   make(map[string]time.Duration),
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.network, s.addr = network, ln.Addr().String()
+	s.serve(ln)
+
+	return s, nil
+}
+
+// Addr returns the host:port or socket path the server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Host returns the host part of Addr, or the socket path.
+func (s *Server) Host() string {
+	if s.network == "unix" {
+		return s.addr
+	}
+	host, _, _ := net.SplitHostPort(s.addr)
+	return host
+}
+
+// Port returns the port part of Addr, or "" for a Unix socket.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.addr)
+	return port
+}
+
+// SetPassword requires clients to send password before any other command.
+// An empty password disables authentication.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Play makes song the current song and starts playing it from the start.
+// A new songid is assigned unless song carries an "Id".
+func (s *Server) Play(song mpd.Attrs) {
+	s.Update(func(status, current mpd.Attrs) {
+		for k := range current {
+			delete(current, k)
+		}
+		for k, v := range song {
+			current[k] = v
+		}
+
+		id := song["Id"]
+		if id == "" {
+			s.songID++
+			id = strconv.Itoa(s.songID)
+			current["Id"] = id
+		}
+		if current["Pos"] == "" {
+			current["Pos"] = "0"
+		}
+		status["songid"] = id
+		status["song"] = current["Pos"]
+		status["state"] = "play"
+		status["elapsed"] = "0.000"
+		if d := song["duration"]; d != "" {
+			status["duration"] = d
+		}
+	}, "player")
+}
+
+// SetState switches the player to "play", "pause" or "stop".
+func (s *Server) SetState(state string) {
+	s.Update(func(status, song mpd.Attrs) {
+		status["state"] = state
+	}, "player")
+}
+
+// SetQueue replaces the queue with songs and wakes clients idling on
+// "playlist". Songs carrying an "Id" keep it, so passing an existing entry
+// at another position moves it; the others are new entries and get an Id.
+func (s *Server) SetQueue(songs ...mpd.Attrs) {
+	s.Update(func(status, _ mpd.Attrs) {
+		version, _ := strconv.Atoi(status["playlist"])
+		version++
+
+		queue := make([]queueEntry, len(songs))
+		for pos, song := range songs {
+			entry := make(mpd.Attrs, len(song)+2)
+			for k, v := range song {
+				entry[k] = v
+			}
+			if entry["Id"] == "" {
+				s.songID++
+				entry["Id"] = strconv.Itoa(s.songID)
+			}
+			entry["Pos"] = strconv.Itoa(pos)
+
+			queue[pos] = queueEntry{song: entry, version: version}
+			if pos < len(s.queue) && s.queue[pos].song["Id"] == entry["Id"] {
+				queue[pos].version = s.queue[pos].version
+			}
+		}
+
+		s.queue = queue
+		status["playlist"] = strconv.Itoa(version)
+		status["playlistlength"] = strconv.Itoa(len(queue))
+	}, "playlist")
+}
+
+// AddAlbums adds albums by artist to the library listed by "list album
+// group albumartist". Pair it with Update on "updating_db" and the
+// "update" and "database" subsystems to script a database update.
+func (s *Server) AddAlbums(artist string, albums ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.library[artist] = append(s.library[artist], albums...)
+}
+
+// AddOutput adds an audio output and returns its id.
+func (s *Server) AddOutput(name, plugin string, enabled bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs = append(s.outputs, output{name: name, plugin: plugin, enabled: enabled})
+	return len(s.outputs) - 1
+}
+
+// SetOutput switches an output on or off, as another client would, and
+// wakes clients idling on "output".
+func (s *Server) SetOutput(id int, enabled bool) {
+	s.mu.Lock()
+	s.outputs[id].enabled = enabled
+	s.mu.Unlock()
+
+	s.Notify("output")
+}
+
+// SendMessage sends text to every client subscribed to channel, as
+// another client's sendmessage would.
+func (s *Server) SendMessage(channel, text string) {
+	s.mu.Lock()
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.deliver(channel, text)
+	}
+}
+
+// SetReplayGain sets the mode reported by replay_gain_status and wakes
+// clients idling on "options".
+func (s *Server) SetReplayGain(mode string) {
+	s.mu.Lock()
+	s.rgMode = mode
+	s.mu.Unlock()
+
+	s.Notify("options")
+}
+
+// Update lets fn modify the status and current song, then wakes idle
+// clients waiting on any of subsystems.
+func (s *Server) Update(fn func(status, song mpd.Attrs), subsystems ...string) {
+	s.mu.Lock()
+	fn(s.status, s.song)
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.notify(subsystems)
+	}
+}
+
+// Notify wakes idle clients waiting on subsystems without changing anything.
+func (s *Server) Notify(subsystems ...string) {
+	s.Update(func(mpd.Attrs, mpd.Attrs) {}, subsystems...)
+}
+
+// SetPicture sets the embedded artwork returned by readpicture for uri.
+func (s *Server) SetPicture(uri string, data []byte, mimeType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pictures[uri] = picture{data: data, typ: mimeType}
+}
+
+// SetAlbumArt sets the cover file returned by albumart for uri.
+func (s *Server) SetAlbumArt(uri string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.art[uri] = picture{data: data}
+}
+
+// Fail makes every following cmd fail with an ACK carrying message. An
+// empty message clears the failure.
+func (s *Server) Fail(cmd, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if message == "" {
+		delete(s.failures, cmd)
+		return
+	}
+	s.failures[cmd] = message
+}
+
+// Stall makes the server wait d before answering every following cmd, as
+// a hung MPD would, or until the client hangs up. Zero answers at once
+// again.
+func (s *Server) Stall(cmd string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d <= 0 {
+		delete(s.stalls, cmd)
+		return
+	}
+	s.stalls[cmd] = d
+}
+
+// Commands returns every command line received so far, across all clients.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// ResetCommands clears the list returned by Commands.
+func (s *Server) ResetCommands() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = nil
+}
+
+// Disconnect drops every client connection, as happens when the network
+// between client and MPD breaks. New connections are still accepted.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.conn.Close()
+	}
+}
+
+// Restart simulates an MPD restart: all clients are dropped and the
+// listener is closed and reopened on the same address. Player state is
+// kept, as MPD does with its state file.
+func (s *Server) Restart() error {
+	s.shutdown()
+
+	ln, err := net.Listen(s.network, s.addr)
+	if err != nil {
+		return err
+	}
+	s.serve(ln)
+	return nil
+}
+
+// Close stops the server and drops every client.
+func (s *Server) Close() {
+	s.shutdown()
+}
+
+func (s *Server) shutdown() {
+	s.mu.Lock()
+	if s.ln != nil {
+		s.ln.Close()
+		s.ln = nil
+	}
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.conn.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) serve(ln net.Listener) {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			c := newClient(s, conn)
+			s.mu.Lock()
+			s.clients[c] = true
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				c.run()
+
+				s.mu.Lock()
+				delete(s.clients, c)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+}
+
+// clientList must be called with s.mu held.
+func (s *Server) clientList() []*client {
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// client is one connection to the server.
+type client struct {
+	srv    *Server
+	conn   net.Conn
+	w      *bufio.Writer
+	authed bool
+	index  int // position in the running command list, for ACKs
+
+	mu       sync.Mutex
+	pending  map[string]bool // subsystems changed since the last idle
+	wake     chan struct{}
+	gone     chan struct{}   // closed once the client hung up
+	channels map[string]bool // subscribed channels
+	inbox    [][2]string     // channel and text of unread messages
+}
+
+func newClient(s *Server, conn net.Conn) *client {
+	return &client{
+		srv:      s,
+		conn:     conn,
+		w:        bufio.NewWriter(conn),
+		pending:  make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+		gone:     make(chan struct{}),
+		channels: make(map[string]bool),
+	}
+}
+
+// deliver queues a message if the client is subscribed to channel.
+func (c *client) deliver(channel, text string) {
+	c.mu.Lock()
+	subscribed := c.channels[channel]
+	if subscribed {
+		c.inbox = append(c.inbox, [2]string{channel, text})
+	}
+	c.mu.Unlock()
+
+	if subscribed {
+		c.notify([]string{"message"})
+	}
+}
+
+func (c *client) notify(subsystems []string) {
+	c.mu.Lock()
+	for _, name := range subsystems {
+		c.pending[name] = true
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takePending removes and returns the pending subsystems matching filter,
+// or all of them if filter is empty.
+func (c *client) takePending(filter []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var changed []string
+	for name := range c.pending {
+		if len(filter) == 0 || contains(filter, name) {
+			changed = append(changed, name)
+			delete(c.pending, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (c *client) run() {
+	defer c.conn.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer close(c.gone)
+		sc := bufio.NewScanner(c.conn)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+	defer func() {
+		// Unblock the reader if it is waiting to hand over a line.
+		c.conn.Close()
+		for range lines {
+		}
+	}()
+
+	fmt.Fprintf(c.w, "OK MPD %s\n", Version)
+	c.w.Flush()
+
+	for line := range lines {
+		c.srv.mu.Lock()
+		c.srv.commands = append(c.srv.commands, line)
+		c.srv.mu.Unlock()
+
+		name, args := parseCommand(line)
+		switch name {
+		case "close":
+			return
+		case "idle":
+			if !c.idle(args, lines) {
+				return
+			}
+		case "noidle":
+			// Like MPD, ignore a noidle that arrives after idle returned.
+		case "command_list_begin", "command_list_ok_begin":
+			if !c.list(name, lines) {
+				return
+			}
+		default:
+			c.exec(name, args)
+		}
+
+		if err := c.w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// idle waits for a change in one of subsystems or for noidle. It reports
+// false if the connection went away.
+func (c *client) idle(subsystems []string, lines <-chan string) bool {
+	if !c.allowed("idle") {
+		return true
+	}
+
+	for {
+		if changed := c.takePending(subsystems); len(changed) > 0 {
+			for _, name := range changed {
+				fmt.Fprintf(c.w, "changed: %s\n", name)
+			}
+			fmt.Fprintln(c.w, "OK")
+			return true
+		}
+
+		select {
+		case <-c.wake:
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			c.srv.mu.Lock()
+			c.srv.commands = append(c.srv.commands, line)
+			c.srv.mu.Unlock()
+			if strings.TrimSpace(line) != "noidle" {
+				// Anything but noidle while idle is a protocol error.
+				return false
+			}
+			fmt.Fprintln(c.w, "OK")
+			return true
+		}
+	}
+}
+
+// allowed writes a permission ACK and reports false if cmd needs a
+// password the client has not sent.
+func (c *client) allowed(cmd string) bool {
+	c.srv.mu.Lock()
+	locked := c.srv.password != "" && !c.authed
+	c.srv.mu.Unlock()
+
+	if locked {
+		c.ack(ackPermission, cmd, fmt.Sprintf("you don't have permission for %q", cmd))
+		return false
+	}
+	return true
+}
+
+func (c *client) exec(name string, args []string) {
+	if c.reply(name, args) {
+		fmt.Fprintln(c.w, "OK")
+	}
+}
+
+// list runs a command list started by begin, answering each command with
+// list_OK if begin is command_list_ok_begin. Like MPD it stops at the
+// first failing command.
+func (c *client) list(begin string, lines <-chan string) bool {
+	var cmds []string
+	for line := range lines {
+		c.srv.mu.Lock()
+		c.srv.commands = append(c.srv.commands, line)
+		c.srv.mu.Unlock()
+
+		if strings.TrimSpace(line) == "command_list_end" {
+			for i, cmd := range cmds {
+				c.index = i
+				name, args := parseCommand(cmd)
+				if !c.reply(name, args) {
+					c.index = 0
+					return true
+				}
+				if begin == "command_list_ok_begin" {
+					fmt.Fprintln(c.w, "list_OK")
+				}
+			}
+			c.index = 0
+			fmt.Fprintln(c.w, "OK")
+			return true
+		}
+		cmds = append(cmds, line)
+	}
+	return false
+}
+
+// reply writes the response body of one command without the final OK. It
+// reports false if it wrote an ACK instead.
+func (c *client) reply(name string, args []string) bool {
+	if name == "password" {
+		c.srv.mu.Lock()
+		ok := len(args) == 1 && args[0] == c.srv.password
+		c.srv.mu.Unlock()
+
+		if !ok {
+			c.ack(ackPassword, name, "incorrect password")
+			return false
+		}
+		c.authed = true
+		return true
+	}
+
+	if name != "ping" && !c.allowed(name) {
+		return false
+	}
+
+	c.srv.mu.Lock()
+	stall := c.srv.stalls[name]
+	c.srv.mu.Unlock()
+	if stall > 0 {
+		select {
+		case <-time.After(stall):
+		case <-c.gone:
+		}
+	}
+
+	c.srv.mu.Lock()
+	msg, failing := c.srv.failures[name]
+	c.srv.mu.Unlock()
+	if failing {
+		c.ack(ackUnknown, name, msg)
+		return false
+	}
+
+	switch name {
+	case "ping":
+	case "status":
+		c.srv.mu.Lock()
+		writeAttrs(c.w, c.srv.status)
+		c.srv.mu.Unlock()
+	case "currentsong":
+		c.srv.mu.Lock()
+		if c.srv.song["file"] != "" {
+			writeSong(c.w, c.srv.song)
+		}
+		c.srv.mu.Unlock()
+	case "playlistinfo", "plchanges":
+		since := -1
+		if name == "plchanges" {
+			if len(args) != 1 {
+				c.ack(ackArg, name, "wrong number of arguments")
+				return false
+			}
+			since, _ = strconv.Atoi(args[0])
+		}
+		c.srv.mu.Lock()
+		for _, entry := range c.srv.queue {
+			if entry.version > since {
+				writeSong(c.w, entry.song)
+			}
+		}
+		c.srv.mu.Unlock()
+	case "clearerror":
+		c.srv.Update(func(status, _ mpd.Attrs) { delete(status, "error") }, "player")
+	case "playlistid":
+		if len(args) != 1 {
+			c.ack(ackArg, name, "wrong number of arguments")
+			return false
+		}
+		c.srv.mu.Lock()
+		found := false
+		for _, entry := range c.srv.queue {
+			if entry.song["Id"] == args[0] {
+				writeSong(c.w, entry.song)
+				found = true
+			}
+		}
+		c.srv.mu.Unlock()
+		if !found {
+			c.ack(ackNoExist, name, "No such song")
+			return false
+		}
+	case "list":
+		if len(args) != 3 || args[0] != "album" || args[1] != "group" || args[2] != "albumartist" {
+			c.ack(ackArg, name, "only \"list album group albumartist\" is supported")
+			return false
+		}
+		c.srv.mu.Lock()
+		artists := make([]string, 0, len(c.srv.library))
+		for artist := range c.srv.library {
+			artists = append(artists, artist)
+		}
+		sort.Strings(artists)
+		for _, artist := range artists {
+			fmt.Fprintf(c.w, "AlbumArtist: %s\n", artist)
+			for _, album := range c.srv.library[artist] {
+				fmt.Fprintf(c.w, "Album: %s\n", album)
+			}
+		}
+		c.srv.mu.Unlock()
+	case "outputs":
+		c.srv.mu.Lock()
+		for id, o := range c.srv.outputs {
+			enabled := 0
+			if o.enabled {
+				enabled = 1
+			}
+			fmt.Fprintf(c.w, "outputid: %d\noutputname: %s\nplugin: %s\noutputenabled: %d\n", id, o.name, o.plugin, enabled)
+		}
+		c.srv.mu.Unlock()
+	case "enableoutput", "disableoutput", "toggleoutput":
+		id := -1
+		if len(args) == 1 {
+			id, _ = strconv.Atoi(args[0])
+		}
+		c.srv.mu.Lock()
+		ok := id >= 0 && id < len(c.srv.outputs)
+		if ok {
+			o := &c.srv.outputs[id]
+			switch name {
+			case "enableoutput":
+				o.enabled = true
+			case "disableoutput":
+				o.enabled = false
+			default:
+				o.enabled = !o.enabled
+			}
+		}
+		c.srv.mu.Unlock()
+		if !ok {
+			c.ack(ackNoExist, name, "No such audio output")
+			return false
+		}
+		c.srv.Notify("output")
+	case "subscribe", "unsubscribe":
+		if len(args) != 1 {
+			c.ack(ackArg, name, "wrong number of arguments")
+			return false
+		}
+		c.mu.Lock()
+		c.channels[args[0]] = name == "subscribe"
+		c.mu.Unlock()
+	case "readmessages":
+		c.mu.Lock()
+		for _, m := range c.inbox {
+			fmt.Fprintf(c.w, "channel: %s\nmessage: %s\n", m[0], m[1])
+		}
+		c.inbox = nil
+		c.mu.Unlock()
+	case "sendmessage":
+		if len(args) != 2 {
+			c.ack(ackArg, name, "wrong number of arguments")
+			return false
+		}
+		c.srv.SendMessage(args[0], args[1])
+	case "replay_gain_status":
+		c.srv.mu.Lock()
+		fmt.Fprintf(c.w, "replay_gain_mode: %s\n", c.srv.rgMode)
+		c.srv.mu.Unlock()
+	case "partition":
+		// All partitions share the one fake player.
+		if len(args) != 1 {
+			c.ack(ackArg, name, "wrong number of arguments")
+			return false
+		}
+	case "readpicture", "albumart":
+		return c.binary(name, args)
+	default:
+		c.ack(ackUnknown, "", fmt.Sprintf("unknown command %q", name))
+		return false
+	}
+
+	return true
+}
+
+// binary answers readpicture and albumart. It reports false if it already
+// wrote an ACK.
+func (c *client) binary(name string, args []string) bool {
+	if len(args) < 2 {
+		c.ack(ackArg, name, "wrong number of arguments")
+		return false
+	}
+	offset, err := strconv.Atoi(args[1])
+	if err != nil || offset < 0 {
+		c.ack(ackArg, name, "bad offset")
+		return false
+	}
+
+	c.srv.mu.Lock()
+	store := c.srv.pictures
+	if name == "albumart" {
+		store = c.srv.art
+	}
+	pic, ok := store[args[0]]
+	c.srv.mu.Unlock()
+
+	if !ok {
+		if name == "albumart" {
+			c.ack(ackNoExist, name, "No file exists")
+			return false
+		}
+		// readpicture answers a bare OK when there is no picture.
+		return true
+	}
+	if offset > len(pic.data) {
+		c.ack(ackArg, name, "Bad file offset")
+		return false
+	}
+
+	chunk := pic.data[offset:]
+	if len(chunk) > chunkSize {
+		chunk = chunk[:chunkSize]
+	}
+
+	fmt.Fprintf(c.w, "size: %d\n", len(pic.data))
+	if pic.typ != "" {
+		fmt.Fprintf(c.w, "type: %s\n", pic.typ)
+	}
+	fmt.Fprintf(c.w, "binary: %d\n", len(chunk))
+	c.w.Write(chunk)
+	fmt.Fprintln(c.w)
+	return true
+}
+
+func (c *client) ack(code int, cmd, message string) {
+	fmt.Fprintf(c.w, "ACK [%d@%d] {%s} %s\n", code, c.index, cmd, message)
+}
+
+func writeAttrs(w *bufio.Writer, attrs mpd.Attrs) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\n", k, attrs[k])
+	}
+}
+
+// writeSong writes a song with "file" first, as MPD does.
+func writeSong(w *bufio.Writer, song mpd.Attrs) {
+	fmt.Fprintf(w, "file: %s\n", song["file"])
+	rest := make(mpd.Attrs, len(song))
+	for k, v := range song {
+		if k != "file" {
+			rest[k] = v
+		}
+	}
+	writeAttrs(w, rest)
+}
+
+// parseCommand splits a command line into its name and arguments,
+// honouring double quotes and backslash escapes.
+func parseCommand(line string) (string, []string) {
+	var (
+		fields  []string
+		cur     strings.Builder
+		quoted  bool
+		escaped bool
+		inField bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
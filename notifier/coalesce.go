@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+// Coalescer holds back events of some kinds until they settle: a burst of
+// such events from one source, e.g. while a volume slider is dragged, is
+// delivered as a single event once window has passed without another one.
+// Other events go straight through, except that a held song change is
+// dropped when its source stops or pauses, and delivered first when the
+// source changes state otherwise, so that it is not overtaken.
+type Coalescer struct {
+	next   Notifier
+	window time.Duration
+	kinds  map[monitor.Kind]bool
+
+	// OnError, if set, receives errors from delayed deliveries, which
+	// cannot be returned from Notify.
+	OnError func(error)
+
+	sendMu sync.Mutex // serialises deliveries to next; taken before mu
+
+	mu      sync.Mutex
+	pending map[string]*burst
+	closed  bool
+}
+
+// burst is a run of coalesced events from one source.
+type burst struct {
+	source      string
+	first, last monitor.Event
+	timer       *time.Timer
+}
+
+// NewCoalescer returns a Coalescer delivering to next. A window of zero or
+// less disables coalescing.
+func NewCoalescer(next Notifier, window time.Duration, kinds ...monitor.Kind) *Coalescer {
+	c := &Coalescer{
+		next:    next,
+		window:  window,
+		kinds:   make(map[monitor.Kind]bool, len(kinds)),
+		pending: make(map[string]*burst),
+	}
+	for _, k := range kinds {
+		c.kinds[k] = true
+	}
+	return c
+}
+
+// Register registers next.
+func (c *Coalescer) Register() error {
+	return c.next.Register()
+}
+
+// Notify delivers ev, or holds it back if its kind is coalesced.
+func (c *Coalescer) Notify(ev monitor.Event) error {
+	if c.window <= 0 {
+		return c.deliver(ev)
+	}
+	source := ev.Snapshot().Source()
+	if !c.kinds[ev.Kind()] {
+		return c.pass(source, ev)
+	}
+
+	key := string(ev.Kind()) + "\x00" + source
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	if b, ok := c.pending[key]; ok {
+		b.last = ev
+		b.timer.Reset(c.window)
+		return nil
+	}
+
+	b := &burst{source: source, first: ev, last: ev}
+	b.timer = time.AfterFunc(c.window, func() { c.flush(key) })
+	c.pending[key] = b
+	return nil
+}
+
+func (c *Coalescer) flush(key string) {
+	// Hold sendMu while taking the burst, so that pass cannot deliver a
+	// later event in between
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	b, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := c.next.Notify(span(b.first, b.last)); err != nil && c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// pass delivers ev, which is not coalesced, after settling the bursts of
+// source it supersedes or must not overtake.
+func (c *Coalescer) pass(source string, ev monitor.Event) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	var due []*burst
+	c.mu.Lock()
+	for key, b := range c.pending {
+		if b.source != source {
+			continue
+		}
+		action := settle(b.last, ev)
+		if action == settleKeep {
+			continue
+		}
+		b.timer.Stop()
+		delete(c.pending, key)
+		if action == settleFlush {
+			due = append(due, b)
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, b := range due {
+		if err := c.next.Notify(span(b.first, b.last)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.next.Notify(ev); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Coalescer) deliver(ev monitor.Event) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.next.Notify(ev)
+}
+
+// Close drops events still held back and closes next.
+func (c *Coalescer) Close() error {
+	c.mu.Lock()
+	for key, b := range c.pending {
+		b.timer.Stop()
+		delete(c.pending, key)
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	return c.next.Close()
+}
+
+// Ways to settle a held burst when another event of its source passes.
+const (
+	settleKeep  = iota // hold it further
+	settleFlush        // deliver it now, before the event
+	settleDrop         // the event makes it moot
+)
+
+// settle tells what to do with a burst ending in held when ev passes: a
+// song change is moot once playback stops or pauses and is delivered
+// before any other state change.
+func settle(held, ev monitor.Event) int {
+	state, ok := ev.(monitor.StateChanged)
+	if _, song := held.(monitor.SongChanged); !song || !ok {
+		return settleKeep
+	}
+	if state.New == "stop" || state.New == "pause" {
+		return settleDrop
+	}
+	return settleFlush
+}
+
+// span returns last with its old value taken from first, so that a burst
+// reads as one change.
+func span(first, last monitor.Event) monitor.Event {
+	switch l := last.(type) {
+	case monitor.VolumeChanged:
+		if f, ok := first.(monitor.VolumeChanged); ok {
+			l.Old = f.Old
+		}
+		return l
+	case monitor.SongChanged:
+		if f, ok := first.(monitor.SongChanged); ok {
+			l.Old = f.Old
+		}
+		return l
+	}
+	return last
+}
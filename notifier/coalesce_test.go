@@ -0,0 +1,140 @@
+package notifier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/notifier"
+)
+
+const window = 50 * time.Millisecond
+
+func song(file string) monitor.SongChanged {
+	return monitor.SongChanged{New: mpd.Attrs{"file": file}}
+}
+
+func state(old, new string) monitor.StateChanged {
+	return monitor.StateChanged{Old: old, New: new}
+}
+
+// settled waits for c's window to pass and returns what rec got.
+func settled(rec *recorder) []monitor.Event {
+	time.Sleep(3 * window)
+	return rec.Events()
+}
+
+func TestCoalesceSkips(t *testing.T) {
+	rec := &recorder{}
+	c := notifier.NewCoalescer(rec, window, monitor.KindSongChanged)
+	defer c.Close()
+
+	first := song("a.flac")
+	first.Old = mpd.Attrs{"file": "z.flac"}
+	c.Notify(first)
+	c.Notify(song("b.flac"))
+	c.Notify(song("c.flac"))
+
+	got := settled(rec)
+	if len(got) != 1 {
+		t.Fatalf("delivered %d events, want 1", len(got))
+	}
+	s := got[0].(monitor.SongChanged)
+	if s.Old["file"] != "z.flac" || s.New["file"] != "c.flac" {
+		t.Errorf("delivered %s -> %s, want z.flac -> c.flac", s.Old["file"], s.New["file"])
+	}
+}
+
+func TestCoalesceDropsSongOnPause(t *testing.T) {
+	for _, to := range []string{"pause", "stop"} {
+		rec := &recorder{}
+		c := notifier.NewCoalescer(rec, window, monitor.KindSongChanged)
+
+		c.Notify(song("a.flac"))
+		c.Notify(state("play", to))
+
+		got := settled(rec)
+		if len(got) != 1 || got[0].Kind() != monitor.KindStateChanged {
+			t.Errorf("%s: delivered %v, want the state change only", to, got)
+		}
+		c.Close()
+	}
+}
+
+func TestCoalesceKeepsOrder(t *testing.T) {
+	rec := &recorder{}
+	c := notifier.NewCoalescer(rec, window, monitor.KindSongChanged)
+	defer c.Close()
+
+	c.Notify(song("a.flac"))
+	c.Notify(state("pause", "play"))
+
+	got := rec.Events()
+	if len(got) != 2 || got[0].Kind() != monitor.KindSongChanged || got[1].Kind() != monitor.KindStateChanged {
+		t.Fatalf("delivered %v, want the song change, then the state change", got)
+	}
+	if got := settled(rec); len(got) != 2 {
+		t.Errorf("delivered the song change again: %v", got)
+	}
+}
+
+func TestCoalesceOtherEventsPass(t *testing.T) {
+	rec := &recorder{}
+	c := notifier.NewCoalescer(rec, window, monitor.KindSongChanged)
+	defer c.Close()
+
+	c.Notify(song("a.flac"))
+	c.Notify(monitor.VolumeChanged{Old: 10, New: 20})
+	if got := rec.Events(); len(got) != 1 || got[0].Kind() != monitor.KindVolumeChanged {
+		t.Errorf("delivered %v, want the volume change only", got)
+	}
+	if got := settled(rec); len(got) != 2 {
+		t.Errorf("delivered %d events, want 2", len(got))
+	}
+}
+
+func volume(source string, old, new int) monitor.VolumeChanged {
+	return monitor.VolumeChanged{Header: monitor.Header{Current: monitor.Snapshot{Server: source}}, Old: old, New: new}
+}
+
+func TestCoalesceVolumeBurst(t *testing.T) {
+	rec := &recorder{}
+	c := notifier.NewCoalescer(rec, window, monitor.KindVolumeChanged)
+	defer c.Close()
+
+	// A slider dragged on two servers at once
+	for _, step := range [][2]int{{10, 20}, {20, 35}, {35, 45}} {
+		c.Notify(volume("home", step[0], step[1]))
+		c.Notify(volume("office", step[1], step[0]))
+	}
+	if got := rec.Events(); len(got) != 0 {
+		t.Fatalf("delivered %v before the burst settled", got)
+	}
+
+	got := settled(rec)
+	if len(got) != 2 {
+		t.Fatalf("delivered %d events, want one per server", len(got))
+	}
+	want := map[string][2]int{"home": {10, 45}, "office": {20, 35}}
+	for _, ev := range got {
+		v := ev.(monitor.VolumeChanged)
+		source := v.Snapshot().Source()
+		if w := want[source]; v.Old != w[0] || v.New != w[1] {
+			t.Errorf("%s: volume %d -> %d, want %d -> %d", source, v.Old, v.New, w[0], w[1])
+		}
+	}
+}
+
+func TestCoalesceDisabled(t *testing.T) {
+	rec := &recorder{}
+	c := notifier.NewCoalescer(rec, 0, monitor.KindVolumeChanged)
+	defer c.Close()
+
+	c.Notify(volume("", 10, 20))
+	c.Notify(volume("", 20, 30))
+	if got := rec.Events(); len(got) != 2 {
+		t.Errorf("delivered %d events at once, want 2", len(got))
+	}
+}
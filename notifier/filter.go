@@ -0,0 +1,38 @@
+package notifier
+
+import "github.com/cumulus13/go-mpdmon/monitor"
+
+// Filter passes every event except those of some kinds on to a Notifier,
+// for notifications the user switched off.
+type Filter struct {
+	next Notifier
+	drop map[monitor.Kind]bool
+}
+
+// NewFilter returns a Filter delivering to next all events but those of
+// the given kinds.
+func NewFilter(next Notifier, drop ...monitor.Kind) *Filter {
+	f := &Filter{next: next, drop: make(map[monitor.Kind]bool, len(drop))}
+	for _, k := range drop {
+		f.drop[k] = true
+	}
+	return f
+}
+
+// Register registers next.
+func (f *Filter) Register() error {
+	return f.next.Register()
+}
+
+// Notify delivers ev unless its kind is filtered out.
+func (f *Filter) Notify(ev monitor.Event) error {
+	if f.drop[ev.Kind()] {
+		return nil
+	}
+	return f.next.Notify(ev)
+}
+
+// Close closes next.
+func (f *Filter) Close() error {
+	return f.next.Close()
+}
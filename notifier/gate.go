@@ -0,0 +1,35 @@
+package notifier
+
+import "github.com/cumulus13/go-mpdmon/monitor"
+
+// Gate passes events on to a Notifier only while open reports true, e.g. to
+// mute notifications. Placed next to the backends, it also holds back
+// events a Coalescer releases after the gate closed.
+type Gate struct {
+	next Notifier
+	open func() bool
+}
+
+// NewGate returns a Gate delivering to next while open reports true. open
+// is called for every event, possibly from several goroutines.
+func NewGate(next Notifier, open func() bool) *Gate {
+	return &Gate{next: next, open: open}
+}
+
+// Register registers next.
+func (g *Gate) Register() error {
+	return g.next.Register()
+}
+
+// Notify delivers ev if the gate is open.
+func (g *Gate) Notify(ev monitor.Event) error {
+	if !g.open() {
+		return nil
+	}
+	return g.next.Notify(ev)
+}
+
+// Close closes next.
+func (g *Gate) Close() error {
+	return g.next.Close()
+}
@@ -0,0 +1,40 @@
+package notifier_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/notifier"
+)
+
+func TestGate(t *testing.T) {
+	var open atomic.Bool
+	open.Store(true)
+	rec := &recorder{}
+	gate := notifier.NewGate(rec, open.Load)
+
+	gate.Notify(monitor.VolumeChanged{Old: 10, New: 20})
+	open.Store(false)
+	gate.Notify(monitor.VolumeChanged{Old: 20, New: 30})
+	if got := len(rec.Events()); got != 1 {
+		t.Errorf("delivered %d events, want 1", got)
+	}
+}
+
+func TestGateHoldsBackCoalescedEvents(t *testing.T) {
+	var open atomic.Bool
+	open.Store(true)
+	rec := &recorder{}
+	c := notifier.NewCoalescer(notifier.NewGate(rec, open.Load), 20*time.Millisecond, monitor.KindVolumeChanged)
+	defer c.Close()
+
+	// Muted while the coalescer still holds the event
+	c.Notify(monitor.VolumeChanged{Old: 10, New: 20})
+	open.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	if got := rec.Events(); len(got) != 0 {
+		t.Errorf("delivered %v after the gate closed", got)
+	}
+}
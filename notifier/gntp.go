@@ -0,0 +1,273 @@
+// Package notifier delivers monitor events as desktop notifications.
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cumulus13/go-gntp"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/render"
+)
+
+// ArtworkSource fetches cover art for a song URI from the named server, as
+// in Snapshot.Server. monitor.Group implements it.
+type ArtworkSource interface {
+	AlbumArt(server, uri string) ([]byte, error)
+}
+
+// priorityHigh is the GNTP priority of error notifications, on the scale
+// from -2 (very low) to 2 (emergency).
+const priorityHigh = 1
+
+// GNTP sends notifications to a Growl-compatible server.
+type GNTP struct {
+	client  *gntp.Client
+	artwork ArtworkSource
+}
+
+// NewGNTP returns a GNTP notifier for cfg. Cover art is looked up through
+// artwork, which may be nil.
+func NewGNTP(cfg Config, artwork ArtworkSource) *GNTP {
+	client := gntp.NewClient("MPD Monitor").
+		WithHost(cfg.Host).
+		WithPort(cfg.Port).
+		WithTimeout(10 * time.Second)
+
+	// Set icon mode based on config
+	switch strings.ToLower(cfg.IconMode) {
+	case "dataurl":
+		client.WithIconMode(gntp.IconModeDataURL)
+	case "fileurl":
+		client.WithIconMode(gntp.IconModeFileURL)
+	case "httpurl":
+		client.WithIconMode(gntp.IconModeHttpURL)
+	default:
+		// Binary mode is default and recommended for Windows
+		client.WithIconMode(gntp.IconModeBinary)
+	}
+
+	return &GNTP{client: client, artwork: artwork}
+}
+
+// Register announces the notification types to the Growl server.
+func (g *GNTP) Register() error {
+	// Define notification types
+	songChange := gntp.NewNotificationType("song_change").
+		WithDisplayName("Song Changed")
+
+	playerState := gntp.NewNotificationType("player_state").
+		WithDisplayName("Player State")
+
+	volume := gntp.NewNotificationType("volume").
+		WithDisplayName("Volume")
+
+	options := gntp.NewNotificationType("options").
+		WithDisplayName("Playback Options")
+
+	queueChanged := gntp.NewNotificationType("queue_changed").
+		WithDisplayName("Queue Changed")
+
+	updatingDB := gntp.NewNotificationType("updating_db").
+		WithDisplayName("Database Update")
+
+	libraryDigest := gntp.NewNotificationType("library_digest").
+		WithDisplayName("New in Library")
+
+	output := gntp.NewNotificationType("output").
+		WithDisplayName("Audio Output")
+
+	streamTitle := gntp.NewNotificationType("stream_title").
+		WithDisplayName("Stream Title")
+
+	upNext := gntp.NewNotificationType("up_next").
+		WithDisplayName("Up Next")
+
+	mpdError := gntp.NewNotificationType("error").
+		WithDisplayName("MPD Error")
+
+	message := gntp.NewNotificationType("message").
+		WithDisplayName("Channel Message")
+
+	return g.client.Register([]*gntp.NotificationType{
+		songChange, playerState, volume, options, queueChanged, updatingDB, libraryDigest, output, streamTitle, upNext, mpdError, message,
+	})
+}
+
+// Notify sends a notification for ev. Events that carry nothing worth
+// notifying about are ignored.
+func (g *GNTP) Notify(ev monitor.Event) error {
+	snap := ev.Snapshot()
+	currentFile := snap.File()
+
+	switch e := ev.(type) {
+	case monitor.SongChanged:
+		if !snap.Playing() {
+			return nil
+		}
+		title := e.New["Title"]
+		if title == "" {
+			// Streams often announce only the station at first
+			title = e.New["Name"]
+		}
+		if title == "" {
+			title = currentFile
+		}
+		return g.send("song_change", render.Label(snap)+title, render.Message(snap.Song, snap.Status), g.albumArt(snap.Server, currentFile))
+
+	case monitor.StateChanged:
+		var stateMsg string
+		switch e.New {
+		case "play":
+			stateMsg = "▶ Playing"
+		case "pause":
+			stateMsg = "⏸ Paused"
+		case "stop":
+			stateMsg = "⏹ Stopped"
+		default:
+			stateMsg = fmt.Sprintf("State: %s", e.New)
+		}
+
+		message := stateMsg
+		if snap.Playing() {
+			message = render.Message(snap.Song, snap.Status)
+		}
+		return g.send("player_state", render.Label(snap)+stateMsg, message, g.albumArt(snap.Server, currentFile))
+
+	case monitor.VolumeChanged:
+		var title, message string
+		switch {
+		case e.Old == e.New:
+			// A coalesced burst that ended where it started
+			return nil
+		case e.Muted():
+			title = "🔇 Muted"
+			message = render.VolumeBar(0)
+			if e.New < 0 {
+				message = "Volume control unavailable"
+			}
+		case e.Unmuted():
+			title = "🔊 Unmuted"
+			message = render.VolumeBar(e.New)
+		case e.New <= 0:
+			// Between 0 and -1, still silent
+			return nil
+		default:
+			title = "🔊 Volume"
+			message = render.VolumeBar(e.New)
+		}
+		return g.send("volume", render.Label(snap)+title, message, nil)
+
+	case monitor.OptionsChanged:
+		changes := render.OptionChanges(e.Old, e.New)
+		if len(changes) == 0 {
+			return nil
+		}
+		message := render.Badges(e.New)
+		if message == "" {
+			message = "All playback modes off"
+		}
+		return g.send("options", render.Label(snap)+"⚙️ "+strings.Join(changes, ", "), message, nil)
+
+	case monitor.TracksAdded:
+		// Show the cover of what was added
+		icon := g.albumArt(snap.Server, e.Tracks[0]["file"])
+		return g.send("queue_changed", render.Label(snap)+render.QueueSummary(e), render.TrackList(e.Tracks, 5), icon)
+
+	case monitor.TracksRemoved:
+		return g.send("queue_changed", render.Label(snap)+render.QueueSummary(e), render.TrackList(e.Tracks, 5), nil)
+
+	case monitor.TracksMoved, monitor.QueueCleared:
+		return g.send("queue_changed", render.Label(snap)+render.QueueSummary(e), fmt.Sprintf("%d tracks in the queue", snap.PlaylistLength()), nil)
+
+	case monitor.UpdateStarted:
+		return g.send("updating_db", render.Label(snap)+render.UpdateSummary(e), "Scanning the music directory", nil)
+
+	case monitor.UpdateFinished:
+		return g.send("updating_db", render.Label(snap)+render.UpdateSummary(e), fmt.Sprintf("Job %d done", e.Job), nil)
+
+	case monitor.LibraryDigest:
+		message := render.AlbumList(e.Albums, 10)
+		if message == "" {
+			message = strings.Join(e.Artists, "\n")
+		}
+		return g.send("library_digest", render.Label(snap)+render.UpdateSummary(e), message, nil)
+
+	case monitor.OutputChanged:
+		return g.send("output", render.Label(snap)+render.OutputSummary(e.Output), fmt.Sprintf("%s output %d", e.Output.Plugin, e.Output.ID), nil)
+
+	case monitor.StreamTitleChanged:
+		if !snap.Playing() {
+			return nil
+		}
+		_, title := e.Split()
+		return g.send("stream_title", render.Label(snap)+"📻 "+title, render.StreamMessage(e), g.albumArt(snap.Server, currentFile))
+
+	case monitor.UpNext:
+		title := e.Next["Title"]
+		if title == "" {
+			title = e.Next["file"]
+		}
+		message := render.SongInfo(e.Next)
+		if message != "" {
+			message += "\n"
+		}
+		message += fmt.Sprintf("in %v", e.Remaining.Round(time.Second))
+		return g.send("up_next", render.Label(snap)+"⏭ Up next: "+title, message, g.albumArt(snap.Server, e.Next["file"]))
+
+	case monitor.ErrorRaised:
+		// Failed status checks show up as reconnects instead
+		if e.Message == "" {
+			return nil
+		}
+		message := e.Message
+		if currentFile != "" {
+			message += "\n📁 " + currentFile
+		}
+		opts := gntp.NewNotifyOptions().WithPriority(priorityHigh).WithSticky(true)
+		return g.client.NotifyWithOptions("error", render.Label(snap)+"❌ MPD error", message, opts)
+
+	case monitor.MessageReceived:
+		return g.send("message", render.Label(snap)+"💬 "+e.Channel, e.Text, nil)
+	}
+
+	return nil
+}
+
+func (g *GNTP) send(event, title, message string, icon *gntp.Resource) error {
+	opts := gntp.NewNotifyOptions()
+
+	if icon != nil {
+		opts.WithIcon(icon)
+	}
+
+	return g.client.NotifyWithOptions(event, title, message, opts)
+}
+
+// Close implements Notifier. GNTP opens a connection per message, so there
+// is nothing to release.
+func (g *GNTP) Close() error {
+	return nil
+}
+
+func (g *GNTP) albumArt(server, uri string) *gntp.Resource {
+	if uri == "" || g.artwork == nil {
+		return nil
+	}
+
+	artwork, err := g.artwork.AlbumArt(server, uri)
+	if err != nil || len(artwork) == 0 {
+		return nil
+	}
+
+	// Detect content type
+	contentType := "image/jpeg"
+	if len(artwork) > 8 {
+		if artwork[0] == 0x89 && artwork[1] == 0x50 && artwork[2] == 0x4E && artwork[3] == 0x47 {
+			contentType = "image/png"
+		}
+	}
+	return gntp.LoadResourceFromBytes(artwork, contentType)
+}
@@ -0,0 +1,221 @@
+package notifier_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+
+	"github.com/cumulus13/go-mpdmon/gntptest"
+	"github.com/cumulus13/go-mpdmon/monitor"
+	"github.com/cumulus13/go-mpdmon/notifier"
+	"github.com/cumulus13/go-mpdmon/render"
+)
+
+// cover is a PNG signature followed by filler, enough to pass as artwork.
+var cover = append([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}, bytes.Repeat([]byte{1}, 64)...)
+
+// artwork returns cover for every song.
+type artwork struct{}
+
+func (artwork) AlbumArt(server, uri string) ([]byte, error) { return cover, nil }
+
+func newGrowl(t *testing.T) *gntptest.Server {
+	t.Helper()
+	srv, err := gntptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// growlConfig returns a Config for srv.
+func growlConfig(srv *gntptest.Server) notifier.Config {
+	return notifier.Config{Type: "gntp", Host: srv.Host(), Port: srv.Port()}
+}
+
+// registered returns a GNTP notifier for cfg that has registered with the
+// server, as it must before notifying.
+func registered(t *testing.T, cfg notifier.Config, artwork notifier.ArtworkSource) *notifier.GNTP {
+	t.Helper()
+	g := notifier.NewGNTP(cfg, artwork)
+	if err := g.Register(); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// playing returns a SongChanged event for song, playing on server.
+func playing(server string, song mpd.Attrs) monitor.SongChanged {
+	snap := monitor.Snapshot{
+		Server: server,
+		Status: mpd.Attrs{"state": "play", "song": "0", "playlistlength": "1"},
+		Song:   song,
+	}
+	return monitor.SongChanged{Header: monitor.Header{Current: snap}, New: song}
+}
+
+func TestGNTPRegister(t *testing.T) {
+	srv := newGrowl(t)
+	if err := notifier.NewGNTP(growlConfig(srv), nil).Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	regs := srv.Registrations()
+	if len(regs) != 1 {
+		t.Fatalf("got %d registrations, want 1", len(regs))
+	}
+	if app := regs[0].Header("Application-Name"); app != "MPD Monitor" {
+		t.Errorf("Application-Name = %q", app)
+	}
+	names := regs[0].TypeNames()
+	for _, want := range []string{"song_change", "player_state", "volume", "options", "queue_changed", "updating_db",
+		"library_digest", "output", "stream_title", "up_next", "error", "message"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("type %s not registered: %q", want, names)
+		}
+	}
+}
+
+func TestGNTPSongChange(t *testing.T) {
+	srv := newGrowl(t)
+	g := registered(t, growlConfig(srv), artwork{})
+
+	ev := playing("home", mpd.Attrs{"file": "a.flac", "Title": "Blue", "Artist": "Joni Mitchell", "Album": "Blue"})
+	if err := g.Notify(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	got := srv.WaitNotifications(1, time.Second)
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if name := n.Header("Notification-Name"); name != "song_change" {
+		t.Errorf("Notification-Name = %q, want song_change", name)
+	}
+	if title := n.Header("Notification-Title"); title != "[home] Blue" {
+		t.Errorf("Notification-Title = %q, want [home] Blue", title)
+	}
+	if text := n.Header("Notification-Text"); !strings.Contains(text, "Joni Mitchell") {
+		t.Errorf("Notification-Text %q lacks the artist", text)
+	}
+}
+
+func TestGNTPSongChangeWhilePaused(t *testing.T) {
+	srv := newGrowl(t)
+	g := registered(t, growlConfig(srv), nil)
+
+	ev := playing("", mpd.Attrs{"file": "a.flac"})
+	ev.Current.Status["state"] = "pause"
+	if err := g.Notify(ev); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Notifications(); len(got) != 0 {
+		t.Errorf("notified about a paused song: %v", got)
+	}
+}
+
+func TestGNTPIconModes(t *testing.T) {
+	for _, mode := range []string{"binary", "dataurl", "fileurl", "httpurl"} {
+		t.Run(mode, func(t *testing.T) {
+			srv := newGrowl(t)
+			cfg := growlConfig(srv)
+			cfg.IconMode = mode
+			g := registered(t, cfg, artwork{})
+
+			if err := g.Notify(playing("", mpd.Attrs{"file": "a.flac", "Title": "A"})); err != nil {
+				t.Fatal(err)
+			}
+			got := srv.WaitNotifications(1, time.Second)
+			if len(got) != 1 {
+				t.Fatalf("got %d notifications, want 1", len(got))
+			}
+
+			icon := got[0].Header("Notification-Icon")
+			switch mode {
+			case "binary":
+				if !bytes.Equal(got[0].Icon(), cover) {
+					t.Errorf("icon resource has %d bytes, want the %d of the cover", len(got[0].Icon()), len(cover))
+				}
+			case "dataurl":
+				data, ok := strings.CutPrefix(icon, "data:image/png;base64,")
+				if decoded, err := base64.StdEncoding.DecodeString(data); !ok || err != nil || !bytes.Equal(decoded, cover) {
+					t.Errorf("Notification-Icon = %.40q..., want the cover as a PNG data URL", icon)
+				}
+			case "fileurl":
+				if !strings.HasPrefix(icon, "file://") {
+					t.Errorf("Notification-Icon = %q, want a file URL", icon)
+				}
+			case "httpurl":
+				if !strings.HasPrefix(icon, "http://") {
+					t.Errorf("Notification-Icon = %q, want an HTTP URL", icon)
+				}
+			}
+			if mode != "binary" && len(got[0].Resources) != 0 {
+				t.Errorf("sent %d binary resources in %s mode", len(got[0].Resources), mode)
+			}
+		})
+	}
+}
+
+func TestGNTPFail(t *testing.T) {
+	srv := newGrowl(t)
+	g := registered(t, growlConfig(srv), nil)
+
+	// The notification reaches the server, which refuses it
+	srv.Fail(gntptest.ErrUnknownNotification, "unknown notification")
+	if err := g.Notify(playing("", mpd.Attrs{"file": "a.flac"})); err == nil {
+		t.Error("Notify succeeded although the server refused it")
+	}
+	if got := len(srv.Notifications()); got != 1 {
+		t.Fatalf("server received %d notifications, want 1", got)
+	}
+
+	srv.Fail(0, "")
+	if err := g.Notify(playing("", mpd.Attrs{"file": "a.flac"})); err != nil {
+		t.Errorf("Notify after recovery: %v", err)
+	}
+}
+
+func TestGNTPVolume(t *testing.T) {
+	tests := []struct {
+		old, new    int
+		title, text string // empty title: no notification
+	}{
+		{50, 70, "🔊 Volume", render.VolumeBar(70)},
+		{50, 0, "🔇 Muted", render.VolumeBar(0)},
+		{50, -1, "🔇 Muted", "Volume control unavailable"},
+		{0, 40, "🔊 Unmuted", render.VolumeBar(40)},
+		{-1, 30, "🔊 Unmuted", render.VolumeBar(30)},
+		{0, -1, "", ""},
+		{30, 30, "", ""},
+	}
+	for _, tt := range tests {
+		srv := newGrowl(t)
+		g := registered(t, growlConfig(srv), nil)
+
+		if err := g.Notify(volume("", tt.old, tt.new)); err != nil {
+			t.Fatal(err)
+		}
+		got := srv.Notifications()
+		if tt.title == "" {
+			if len(got) != 0 {
+				t.Errorf("%d -> %d: notified %q", tt.old, tt.new, got[0].Header("Notification-Title"))
+			}
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%d -> %d: got %d notifications, want 1", tt.old, tt.new, len(got))
+			continue
+		}
+		if title, text := got[0].Header("Notification-Title"), got[0].Header("Notification-Text"); title != tt.title || text != tt.text {
+			t.Errorf("%d -> %d: notified %q: %q, want %q: %q", tt.old, tt.new, title, text, tt.title, tt.text)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+// Notifier is a notification backend fed by monitor events.
+type Notifier interface {
+	// Register prepares the backend, e.g. announcing notification types to
+	// the server. A Notifier is only used after Register succeeds.
+	Register() error
+	// Notify delivers ev. Backends ignore events they have nothing to say about.
+	Notify(ev monitor.Event) error
+	// Close releases the backend's resources.
+	Close() error
+}
+
+// Config describes one entry of the [[notifiers]] list in config.toml.
+// Which fields are used depends on Type.
+type Config struct {
+	Type     string `toml:"type"` // gntp
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	IconMode string `toml:"icon_mode"` // binary, dataurl, fileurl, httpurl
+}
+
+// String describes the backend for log messages.
+func (c Config) String() string {
+	return fmt.Sprintf("%s %s:%d", strings.ToUpper(c.Type), c.Host, c.Port)
+}
+
+// New returns the backend described by cfg. Cover art is looked up through
+// artwork, which may be nil.
+func New(cfg Config, artwork ArtworkSource) (Notifier, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "gntp", "growl", "":
+		return NewGNTP(cfg, artwork), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+// Multi delivers every event to several notifiers.
+type Multi []Notifier
+
+// Register registers every notifier and returns the combined errors.
+func (m Multi) Register() error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Register(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notify sends ev to every notifier, even if some of them fail.
+func (m Multi) Notify(ev monitor.Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every notifier.
+func (m Multi) Close() error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
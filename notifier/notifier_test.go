@@ -0,0 +1,30 @@
+package notifier_test
+
+import (
+	"sync"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+// recorder is a Notifier keeping the events it is given.
+type recorder struct {
+	mu     sync.Mutex
+	events []monitor.Event
+}
+
+func (r *recorder) Register() error { return nil }
+func (r *recorder) Close() error    { return nil }
+
+func (r *recorder) Notify(ev monitor.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+// Events returns the events delivered so far.
+func (r *recorder) Events() []monitor.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]monitor.Event(nil), r.events...)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+// runOutputs implements the "outputs" subcommand:
+//
+//	outputs                         list the audio outputs
+//	outputs enable|disable|toggle X switch output X (id or name)
+//
+// It uses the first of configs, or the first one of server, and sends
+// its commands on one connection of its own, without watching the player
+// or going through a running monitor.
+func runOutputs(configs []monitor.Config, server string, args []string) error {
+	cfg := configs[0]
+	if server != "" {
+		i := slices.IndexFunc(configs, func(c monitor.Config) bool { return c.Name == server })
+		if i < 0 {
+			return fmt.Errorf("unknown MPD server %q", server)
+		}
+		cfg = configs[i]
+	}
+	cfg.Direct, cfg.Channel = true, ""
+	mon := monitor.New(cfg)
+
+	if len(args) == 0 {
+		outputs, err := mon.Outputs()
+		if err != nil {
+			return err
+		}
+		for _, o := range outputs {
+			mark := " "
+			if o.Enabled {
+				mark = "x"
+			}
+			fmt.Fprintf(os.Stdout, "[%s] %d  %s (%s)\n", mark, o.ID, o.Name, o.Plugin)
+		}
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: outputs [enable|disable|toggle <id|name>]")
+	}
+	var action monitor.OutputAction
+	switch args[0] {
+	case "enable":
+		action = monitor.OutputEnable
+	case "disable":
+		action = monitor.OutputDisable
+	case "toggle":
+		action = monitor.OutputToggle
+	default:
+		return fmt.Errorf("unknown outputs action %q", args[0])
+	}
+
+	find := func(outputs []monitor.Output) (monitor.Output, bool) { return findOutput(outputs, args[1]) }
+	o, err := mon.SwitchOutput(find, action)
+	if errors.Is(err, monitor.ErrNoOutput) {
+		return fmt.Errorf("no output %q", args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "✅ %s: %s\n", args[0], o.Name)
+	return nil
+}
+
+// findOutput looks an output up by id or, case-insensitively, by name.
+func findOutput(outputs []monitor.Output, key string) (monitor.Output, bool) {
+	id, err := strconv.Atoi(key)
+	for _, o := range outputs {
+		if (err == nil && o.ID == id) || strings.EqualFold(o.Name, key) {
+			return o, true
+		}
+	}
+	return monitor.Output{}, false
+}
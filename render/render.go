@@ -0,0 +1,550 @@
+// Package render formats MPD status for the console and for notification bodies.
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+	"golang.org/x/term"
+
+	"github.com/cumulus13/go-mpdmon/monitor"
+)
+
+const (
+	// ANSI color codes for terminal
+	colorReset  = "\033[0m"
+	colorCyan   = "\033[96m"       // track/title
+	colorYellow = "\033[93m"       // artist
+	colorOrange = "\033[38;5;216m" // album
+	colorBlue   = "\033[94m"       // bitrate
+	colorGreen  = "\033[92m"       // filepath
+	colorPurple = "\033[95m"       // playback mode badges
+)
+
+// TerminalWidth returns the width of stdout, or 80 if it is not a terminal.
+func TerminalWidth() int {
+	// Try to get terminal size
+	fd := int(os.Stdout.Fd())
+	width, _, err := term.GetSize(fd)
+	if err != nil || width <= 0 {
+		return 80 // Default fallback
+	}
+	return width
+}
+
+// Separator returns a horizontal rule as wide as the terminal.
+func Separator() string {
+	return strings.Repeat("─", TerminalWidth())
+}
+
+// Bitrate returns the sample rate from the status "audio" field, falling
+// back to the "bitrate" field.
+func Bitrate(attrs mpd.Attrs) string {
+	if bitrate, ok := attrs["audio"]; ok {
+		// audio format: "samplerate:bits:channels"
+		parts := strings.Split(bitrate, ":")
+		if len(parts) >= 1 {
+			sampleRate := parts[0]
+			if sr, err := strconv.Atoi(sampleRate); err == nil {
+				kbps := sr / 1000
+				return fmt.Sprintf("%d kHz", kbps)
+			}
+		}
+	}
+
+	// Fallback to bitrate field if available
+	if bitrate, ok := attrs["bitrate"]; ok {
+		return fmt.Sprintf("%s kbps", bitrate)
+	}
+
+	return "N/A"
+}
+
+// Duration formats a seconds value reported by MPD as m:ss.
+func Duration(seconds string) string {
+	if seconds == "" {
+		return "0:00"
+	}
+
+	sec, err := strconv.ParseFloat(seconds, 64)
+	if err != nil {
+		return "0:00"
+	}
+
+	mins := int(sec) / 60
+	secs := int(sec) % 60
+
+	return fmt.Sprintf("%d:%02d", mins, secs)
+}
+
+// Position formats a position within a song as m:ss.
+func Position(d time.Duration) string {
+	return Duration(strconv.FormatFloat(d.Seconds(), 'f', 3, 64))
+}
+
+// Label returns "[server/partition] " for events from a named server or a
+// partition, to prefix console lines and notification titles, or "" for a
+// single server's default partition.
+func Label(snap monitor.Snapshot) string {
+	source := strings.TrimPrefix(snap.Source(), "/")
+	if source == "" {
+		return ""
+	}
+	return "[" + source + "] "
+}
+
+// VolumeBar draws level (0-100) as an OSD-style bar, e.g. "▮▮▮▮▯▯▯▯▯▯ 40%".
+func VolumeBar(level int) string {
+	if level < 0 {
+		level = 0
+	}
+	if level > 100 {
+		level = 100
+	}
+	filled := (level + 5) / 10
+	return fmt.Sprintf("%s%s %d%%", strings.Repeat("▮", filled), strings.Repeat("▯", 10-filled), level)
+}
+
+// Badges returns the active playback modes as short tags, e.g.
+// "[🔁 repeat] [🔀 random] [🎚 xfade 5s]", or "" if none is active.
+func Badges(o monitor.Options) string {
+	var badges []string
+	if o.Repeat {
+		badges = append(badges, "[🔁 repeat]")
+	}
+	if o.Random {
+		badges = append(badges, "[🔀 random]")
+	}
+	switch o.Single {
+	case "1":
+		badges = append(badges, "[🔂 single]")
+	case "oneshot":
+		badges = append(badges, "[🔂 single once]")
+	}
+	switch o.Consume {
+	case "1":
+		badges = append(badges, "[🗑 consume]")
+	case "oneshot":
+		badges = append(badges, "[🗑 consume once]")
+	}
+	if o.Crossfade > 0 {
+		badges = append(badges, fmt.Sprintf("[🎚 xfade %v]", o.Crossfade))
+	}
+	if o.ReplayGain != "" && o.ReplayGain != "off" {
+		badges = append(badges, fmt.Sprintf("[📈 RG %s]", o.ReplayGain))
+	}
+	return strings.Join(badges, " ")
+}
+
+// OptionChanges describes what differs between old and cur, e.g.
+// ["Repeat on", "Crossfade 5s"].
+func OptionChanges(old, cur monitor.Options) []string {
+	var changes []string
+	onOff := func(name string, on bool) {
+		if on {
+			changes = append(changes, name+" on")
+		} else {
+			changes = append(changes, name+" off")
+		}
+	}
+	mode := func(name, m string) {
+		switch m {
+		case "1":
+			changes = append(changes, name+" on")
+		case "oneshot":
+			changes = append(changes, name+" once")
+		default:
+			changes = append(changes, name+" off")
+		}
+	}
+
+	if old.Repeat != cur.Repeat {
+		onOff("Repeat", cur.Repeat)
+	}
+	if old.Random != cur.Random {
+		onOff("Random", cur.Random)
+	}
+	if old.Single != cur.Single {
+		mode("Single", cur.Single)
+	}
+	if old.Consume != cur.Consume {
+		mode("Consume", cur.Consume)
+	}
+	if old.Crossfade != cur.Crossfade {
+		if cur.Crossfade > 0 {
+			changes = append(changes, fmt.Sprintf("Crossfade %v", cur.Crossfade))
+		} else {
+			changes = append(changes, "Crossfade off")
+		}
+	}
+	if old.ReplayGain != cur.ReplayGain && cur.ReplayGain != "" {
+		changes = append(changes, "Replay gain "+cur.ReplayGain)
+	}
+	return changes
+}
+
+// QueueSummary describes a queue event in one line, e.g. "➕ 3 tracks
+// added from Abbey Road by The Beatles", or returns "" for other events.
+func QueueSummary(ev monitor.Event) string {
+	switch e := ev.(type) {
+	case monitor.TracksAdded:
+		return "➕ " + withSource(count(len(e.Tracks))+" added", e.Tracks)
+	case monitor.TracksRemoved:
+		return "➖ " + withSource(count(len(e.Tracks))+" removed", e.Tracks)
+	case monitor.TracksMoved:
+		if len(e.Moves) == 1 {
+			m := e.Moves[0]
+			return fmt.Sprintf("↕ %s moved from %d to %d", trackName(m.Track), m.From+1, m.To+1)
+		}
+		return fmt.Sprintf("↕ %s moved", count(len(e.Moves)))
+	case monitor.QueueCleared:
+		return fmt.Sprintf("🗑 Queue cleared (%s)", count(len(e.Tracks)))
+	}
+	return ""
+}
+
+// UpdateSummary describes a database update event in one line, or returns
+// "" for other events.
+func UpdateSummary(ev monitor.Event) string {
+	switch e := ev.(type) {
+	case monitor.UpdateStarted:
+		return fmt.Sprintf("🔄 Database update started (job %d)", e.Job)
+	case monitor.UpdateFinished:
+		if e.Duration > 0 {
+			return fmt.Sprintf("✅ Database update finished in %v", e.Duration.Round(time.Second))
+		}
+		return "✅ Database update finished"
+	case monitor.LibraryDigest:
+		var parts []string
+		if n := len(e.Albums); n == 1 {
+			parts = append(parts, "1 new album")
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d new albums", n))
+		}
+		if n := len(e.Artists); n == 1 {
+			parts = append(parts, "1 new artist")
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d new artists", n))
+		}
+		return "📚 " + strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// AlbumList lists up to max albums, one "Artist - Album" per line.
+func AlbumList(albums []monitor.Album, max int) string {
+	var lines []string
+	for i, a := range albums {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(albums)-max))
+			break
+		}
+		if a.Artist != "" {
+			lines = append(lines, a.Artist+" - "+a.Title)
+		} else {
+			lines = append(lines, a.Title)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OutputSummary describes an output switch, e.g. "🔈 Output 'USB DAC'
+// disabled".
+func OutputSummary(o monitor.Output) string {
+	if o.Enabled {
+		return fmt.Sprintf("🔊 Output '%s' enabled", o.Name)
+	}
+	return fmt.Sprintf("🔈 Output '%s' disabled", o.Name)
+}
+
+// StreamMessage returns the notification body for a stream title change:
+// "🎤 artist" and "📡 station", as far as they are known.
+func StreamMessage(e monitor.StreamTitleChanged) string {
+	var lines []string
+	if artist, _ := e.Split(); artist != "" {
+		lines = append(lines, "🎤 "+artist)
+	}
+	if station := e.Station(); station != "" {
+		lines = append(lines, "📡 "+station)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// UpNextSummary describes an UpNext event, e.g. "⏭  Up next in 15s:
+// Artist - Title".
+func UpNextSummary(e monitor.UpNext) string {
+	return fmt.Sprintf("⏭  Up next in %v: %s", e.Remaining.Round(time.Second), trackName(e.Next))
+}
+
+// SongInfo returns "🎤 artist" and "💿 album" lines for song, as far as
+// they are known.
+func SongInfo(song mpd.Attrs) string {
+	var lines []string
+	if artist := song["Artist"]; artist != "" {
+		lines = append(lines, "🎤 "+artist)
+	}
+	if album := song["Album"]; album != "" {
+		lines = append(lines, "💿 "+album)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TrackList lists up to max tracks, one "Artist - Title" per line.
+func TrackList(tracks []mpd.Attrs, max int) string {
+	var lines []string
+	for i, t := range tracks {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(tracks)-max))
+			break
+		}
+		lines = append(lines, trackName(t))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func count(n int) string {
+	if n == 1 {
+		return "1 track"
+	}
+	return fmt.Sprintf("%d tracks", n)
+}
+
+// withSource appends what the tracks have in common: the title of a single
+// track, or the album and artist they share.
+func withSource(what string, tracks []mpd.Attrs) string {
+	if len(tracks) == 1 {
+		return what + ": " + trackName(tracks[0])
+	}
+
+	album, artist := common(tracks, "Album"), common(tracks, "AlbumArtist")
+	if artist == "" {
+		artist = common(tracks, "Artist")
+	}
+	switch {
+	case album != "" && artist != "":
+		return fmt.Sprintf("%s from %s by %s", what, album, artist)
+	case album != "":
+		return fmt.Sprintf("%s from %s", what, album)
+	case artist != "":
+		return fmt.Sprintf("%s by %s", what, artist)
+	}
+	return what
+}
+
+// common returns the value of key shared by all tracks, or "".
+func common(tracks []mpd.Attrs, key string) string {
+	value := tracks[0][key]
+	for _, t := range tracks[1:] {
+		if t[key] != value {
+			return ""
+		}
+	}
+	return value
+}
+
+func trackName(t mpd.Attrs) string {
+	title := t["Title"]
+	if title == "" {
+		title = t["file"]
+	}
+	if artist := t["Artist"]; artist != "" {
+		return artist + " - " + title
+	}
+	return title
+}
+
+// Message returns the plain-text now-playing block used as a notification body.
+func Message(song mpd.Attrs, status mpd.Attrs) string {
+	pos := status["song"]
+	total := status["playlistlength"]
+	elapsed := Duration(status["elapsed"])
+	duration := Duration(song["duration"])
+	track := song["Track"]
+	title := song["Title"]
+	artist := song["Artist"]
+	album := song["Album"]
+	bitrate := Bitrate(status)
+	filepath := song["file"]
+
+	if title == "" {
+		title = filepath
+	}
+
+	if track == "" {
+		track = "?"
+	}
+
+	var sb strings.Builder
+
+	// Position/Total/Track. Title with time
+	sb.WriteString(fmt.Sprintf("%s/%s/%s. %s\n", pos, total, track, title))
+	sb.WriteString(fmt.Sprintf("%s / %s\n", elapsed, duration))
+
+	// Artist
+	if artist != "" {
+		sb.WriteString(fmt.Sprintf("🎤 %s\n", artist))
+	}
+
+	// Album
+	if album != "" {
+		sb.WriteString(fmt.Sprintf("💿 %s\n", album))
+	}
+
+	// Bitrate
+	sb.WriteString(fmt.Sprintf("🎵 %s\n", bitrate))
+
+	// Filepath
+	sb.WriteString(fmt.Sprintf("📁 %s", filepath))
+
+	return sb.String()
+}
+
+// Console returns the colored now-playing block printed to the terminal.
+func Console(song mpd.Attrs, status mpd.Attrs) string {
+	pos := status["song"]
+	total := status["playlistlength"]
+	elapsed := Duration(status["elapsed"])
+	duration := Duration(song["duration"])
+	track := song["Track"]
+	title := song["Title"]
+	artist := song["Artist"]
+	album := song["Album"]
+	bitrate := Bitrate(status)
+	filepath := song["file"]
+
+	if title == "" {
+		title = filepath
+	}
+
+	if track == "" {
+		track = "?"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s▶ %s/%s/%s. %s%s\n", colorCyan, pos, total, track, title, colorReset))
+	sb.WriteString(fmt.Sprintf("%s  🕓 %s / %s%s\n", colorCyan, elapsed, duration, colorReset))
+
+	if artist != "" {
+		sb.WriteString(fmt.Sprintf("%s  🎤 %s%s\n", colorYellow, artist, colorReset))
+	}
+
+	if album != "" {
+		sb.WriteString(fmt.Sprintf("%s  💿 %s%s\n", colorOrange, album, colorReset))
+	}
+
+	sb.WriteString(fmt.Sprintf("%s  🎵 %s%s\n", colorBlue, bitrate, colorReset))
+	sb.WriteString(fmt.Sprintf("%s  📁 %s%s", colorGreen, filepath, colorReset))
+
+	return sb.String()
+}
+
+// Printer writes monitor events to a terminal.
+type Printer struct {
+	out  io.Writer
+	last map[string]string // per source: songid and file of the last block printed
+}
+
+// NewPrinter returns a Printer writing to out.
+func NewPrinter(out io.Writer) *Printer {
+	return &Printer{out: out, last: make(map[string]string)}
+}
+
+// Handle prints ev if it changes what the console shows.
+func (p *Printer) Handle(ev monitor.Event) {
+	snap := ev.Snapshot()
+	label := Label(snap)
+
+	switch e := ev.(type) {
+	case monitor.SongChanged, monitor.StateChanged:
+		if snap.Playing() {
+			key := snap.Status["songid"] + "/" + snap.File()
+			if key == p.last[snap.Source()] {
+				return
+			}
+			p.last[snap.Source()] = key
+			fmt.Fprintln(p.out)
+			fmt.Fprintln(p.out, label+Console(snap.Song, snap.Status))
+			if badges := Badges(snap.Options()); badges != "" {
+				fmt.Fprintf(p.out, "%s  %s%s\n", colorPurple, badges, colorReset)
+			}
+			fmt.Fprintln(p.out, Separator())
+			return
+		}
+
+		if sc, ok := ev.(monitor.StateChanged); ok {
+			delete(p.last, snap.Source())
+			fmt.Fprintf(p.out, "%s⏸  State: %s\n", label, sc.New)
+			fmt.Fprintln(p.out, Separator())
+		}
+
+	case monitor.OptionsChanged:
+		if changes := OptionChanges(e.Old, e.New); len(changes) > 0 {
+			fmt.Fprintf(p.out, "%s%s⚙️  %s%s\n", label, colorPurple, strings.Join(changes, ", "), colorReset)
+		}
+
+	case monitor.TracksAdded, monitor.TracksRemoved, monitor.TracksMoved, monitor.QueueCleared:
+		fmt.Fprintf(p.out, "%s%s\n", label, QueueSummary(ev))
+
+	case monitor.UpdateStarted, monitor.UpdateFinished:
+		fmt.Fprintf(p.out, "%s%s\n", label, UpdateSummary(ev))
+
+	case monitor.LibraryDigest:
+		fmt.Fprintf(p.out, "%s%s\n", label, UpdateSummary(ev))
+		for _, line := range strings.Split(AlbumList(e.Albums, 10), "\n") {
+			if line != "" {
+				fmt.Fprintf(p.out, "   %s\n", line)
+			}
+		}
+
+	case monitor.Seeked:
+		arrow := "⏩"
+		if e.To < e.From {
+			arrow = "⏪"
+		}
+		fmt.Fprintf(p.out, "%s%s Seeked %s → %s\n", label, arrow, Position(e.From), Position(e.To))
+
+	case monitor.TrackRestarted:
+		title := snap.Song["Title"]
+		if title == "" {
+			title = snap.File()
+		}
+		fmt.Fprintf(p.out, "%s⏮  Restarted: %s\n", label, title)
+
+	case monitor.UpNext:
+		fmt.Fprintf(p.out, "%s%s%s%s\n", label, colorCyan, UpNextSummary(e), colorReset)
+
+	case monitor.StreamTitleChanged:
+		artist, title := e.Split()
+		fmt.Fprintf(p.out, "%s%s📻 %s%s\n", label, colorCyan, title, colorReset)
+		if artist != "" {
+			fmt.Fprintf(p.out, "%s  🎤 %s%s\n", colorYellow, artist, colorReset)
+		}
+		if station := e.Station(); station != "" {
+			fmt.Fprintf(p.out, "%s  📡 %s%s\n", colorOrange, station, colorReset)
+		}
+
+	case monitor.OutputChanged:
+		fmt.Fprintf(p.out, "%s%s\n", label, OutputSummary(e.Output))
+
+	case monitor.MessageReceived:
+		fmt.Fprintf(p.out, "%s💬 %s: %s\n", label, e.Channel, e.Text)
+
+	case monitor.ErrorRaised:
+		if e.Message != "" {
+			fmt.Fprintf(p.out, "%s❌ MPD error: %s\n", label, e.Message)
+		}
+
+	case monitor.Reconnecting:
+		delete(p.last, snap.Source())
+		fmt.Fprintf(p.out, "%s🔄 Reconnecting to MPD (%s)\n", label, e.State)
+
+	case monitor.Reconnected:
+		fmt.Fprintf(p.out, "%s✅ Reconnected to MPD after %d attempt(s)\n", label, e.Attempts)
+		fmt.Fprintln(p.out, Separator())
+	}
+}